@@ -0,0 +1,299 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//The categories of the problems found by the analysis.
+const (
+	CategoryContention = "contention"
+	CategoryOrphanLock = "orphan-lock"
+	CategoryClassInit  = "class-init"
+	CategoryDeepStack  = "deep-stack"
+	CategoryEmptyStack = "empty-stack"
+	CategoryManyLocks  = "many-locks"
+	CategoryGCThreads  = "gc-threads"
+	CategoryLifecycle  = "lifecycle"
+	CategoryPriority   = "priority"
+	CategoryFinalizer  = "finalizer"
+	CategoryTruncated  = "truncated"
+	CategoryDeadlock   = "deadlock"
+	CategoryBottleneck = "bottleneck"
+	CategoryIdlePool   = "idle-pool"
+)
+
+//Problem is a finding of the analysis.
+type Problem struct {
+	Category string `json:"category"`
+	Message  string `json:"message"`
+	//TIDs are the threads involved, the one the problem is about first.
+	TIDs []string `json:"tids,omitempty"`
+}
+
+//AnalysisResult holds the problems found by the analysis, sorted by message, then by category and tids.
+//JavaThreadDump.Problems is the flattened view of the same messages.
+type AnalysisResult struct {
+	Problems []Problem `json:"problems"`
+}
+
+//ByCategory returns the problems of the given category.
+func (ar AnalysisResult) ByCategory(category string) []Problem {
+	res := make([]Problem, 0)
+	for _, problem := range ar.Problems {
+		if problem.Category == category {
+			res = append(res, problem)
+		}
+	}
+	return res
+}
+
+func (ar *AnalysisResult) add(category string, tids []string, format string, args ...interface{}) {
+	ar.Problems = append(ar.Problems, Problem{Category: category, Message: fmt.Sprintf(format, args...), TIDs: tids})
+}
+
+func (jtd *JavaThreadDump) analyze() AnalysisResult {
+	th := jtd.thresholds.withDefaults()
+	ar := AnalysisResult{Problems: make([]Problem, 0)}
+	gcThreads := 0
+	for _, jt := range jtd.SystemThreads {
+		if isGCThread(jt.Name) {
+			gcThreads += jt.count()
+		}
+	}
+	for tid, jt := range jtd.Threads {
+		if isGCThread(jt.Name) {
+			gcThreads += jt.count()
+		}
+		if jt.Status == StatusBlocked {
+			for _, lock := range jt.LocksWaiting {
+				if owner := jtd.LockOwners[lock]; owner != "" {
+					ar.add(CategoryContention, []string{tid, owner}, "%s[%s] blocked for %s[%s]. lock %s", jt.Name, tid, owner, jtd.Threads[owner].Name, lock)
+				} else if jtd.ReleasedInWait[lock] == "" {
+					ar.add(CategoryOrphanLock, []string{tid}, "%s[%s] blocked on lock %s with no visible owner in this dump.", jt.Name, tid, lock)
+				}
+			}
+			for _, lock := range jt.LocksReacquiring {
+				if owner := jtd.LockOwners[lock]; owner != "" && owner != tid {
+					ar.add(CategoryContention, []string{tid, owner}, "%s[%s] blocked re-locking after wait() for %s[%s]. lock %s", jt.Name, tid, owner, jtd.Threads[owner].Name, lock)
+				}
+			}
+		}
+		for _, lock := range jt.ParkingOn {
+			if owner := jtd.LockOwners[lock]; owner != "" && owner != tid {
+				ar.add(CategoryContention, []string{tid, owner}, "%s[%s] parked for %s[%s]. lock %s", jt.Name, tid, owner, jtd.Threads[owner].Name, lock)
+			}
+		}
+		for _, lock := range jt.WaitingOn {
+			// The monitor was released by wait(), the thread holding it now is the one that can notify.
+			if owner := jtd.LockOwners[lock]; owner != "" && owner != tid {
+				ar.add(CategoryContention, []string{tid, owner}, "%s[%s] waiting in wait() on the monitor held by %s[%s]. lock %s", jt.Name, tid, owner, jtd.Threads[owner].Name, lock)
+			}
+		}
+		if jt.finalizationBacklog() {
+			ar.add(CategoryFinalizer, []string{tid}, "%s[%s] is %s in %s instead of idle. finalization backlog, risk of OutOfMemoryError.", jt.Name, tid, jt.Status, jt.topFrame())
+		}
+		if frame, ok := jt.classInitFrame(); ok {
+			tids := []string{tid}
+			message := fmt.Sprintf("%s[%s] waiting for class initialization in %s.", jt.Name, tid, frame)
+			for _, lock := range jt.LocksWaiting {
+				if owner := jtd.LockOwners[lock]; owner != "" {
+					tids = append(tids, owner)
+					message += fmt.Sprintf(" lock %s held by %s[%s].", lock, owner, jtd.Threads[owner].Name)
+				}
+			}
+			ar.add(CategoryClassInit, tids, "%s", message)
+		}
+		if jt.StackDepth > th.DeepStackDepth && jt.Status != StatusRunnable {
+			ar.add(CategoryDeepStack, []string{tid}, "%s[%s] waiting with stack depth %d.", jt.Name, tid, jt.StackDepth)
+		}
+		if jt.StackDepth == 0 && jt.Status != StatusNew && jt.Status != StatusTerminated && !hasNoJavaFrames(jt.Name) {
+			ar.add(CategoryEmptyStack, []string{tid}, "%s[%s] has an empty stack. dump probably raced the thread creation/teardown.", jt.Name, tid)
+		}
+		if th.ManyLocksOwned > 0 && len(jt.LocksOwned) >= th.ManyLocksOwned {
+			ar.add(CategoryManyLocks, []string{tid}, "%s[%s] owns %d locks.", jt.Name, tid, len(jt.LocksOwned))
+		}
+	}
+	if th.GCThreadStorm > 0 && gcThreads >= th.GCThreadStorm {
+		ar.add(CategoryGCThreads, nil, "%d GC threads. GC threads probably not limited to the container CPUs.", gcThreads)
+	}
+	for _, status := range []string{StatusNew, StatusTerminated} {
+		if count := jtd.ByStatus[status]; count > 0 {
+			ar.add(CategoryLifecycle, nil, "%d threads in %s state. dump taken during startup/shutdown or thread lifecycle bug.", count, status)
+		}
+	}
+	g := jtd.LockGraph()
+	for _, cycle := range jtd.deadlocks(g) {
+		tids := make([]string, len(cycle))
+		hops := make([]string, len(cycle))
+		for i, hop := range cycle {
+			next := cycle[(i+1)%len(cycle)].TID
+			tids[i] = hop.TID
+			hops[i] = fmt.Sprintf("%s[%s] waits for %s held by %s[%s]", jtd.Threads[hop.TID].Name, hop.TID, hop.Lock, jtd.Threads[next].Name, next)
+		}
+		ar.add(CategoryDeadlock, tids, "DEADLOCK: %s.", strings.Join(hops, ", "))
+	}
+	if jtd.truncatedAt > 0 {
+		ar.add(CategoryTruncated, nil, "dump truncated after %d lines. the analysis is partial.", jtd.truncatedAt)
+	}
+	for _, b := range jtd.bottlenecks(g) {
+		if b.Waiters >= th.BottleneckWaiters {
+			ar.add(CategoryBottleneck, append([]string{b.TID}, b.WaiterTIDs...), "%s[%s] is %s while %d threads wait behind its locks %s.", b.Name, b.TID, b.Status, b.Waiters, strings.Join(b.Locks, ", "))
+		}
+	}
+	jtd.ThreadPools = jtd.threadPools()
+	for name, pool := range jtd.ThreadPools {
+		idle := pool.ByStatus[StatusWaiting] + pool.ByStatus[StatusTimedWaiting]
+		if th.IdlePoolRatio > 0 && pool.Count >= th.LeakMinThreads && float64(idle) >= float64(pool.Count)*th.IdlePoolRatio {
+			ar.add(CategoryIdlePool, nil, "pool %s has %d idle threads out of %d. pool probably oversized.", name, idle, pool.Count)
+		}
+	}
+	commonOSPrios := jtd.commonOSPrios()
+	for _, jt := range jtd.PriorityOutliers() {
+		ar.add(CategoryPriority, []string{jt.TID}, "%s[%s] has os_prio %d while most threads of prio %d have %d. may starve the other threads.", jt.Name, jt.TID, jt.OSPrio, jt.Prio, commonOSPrios[jt.Prio])
+	}
+	for _, detector := range jtd.detectors {
+		ar.Problems = append(ar.Problems, detector(jtd)...)
+	}
+	sort.Slice(ar.Problems, func(i, j int) bool {
+		a, b := ar.Problems[i], ar.Problems[j]
+		if a.Message != b.Message {
+			return a.Message < b.Message
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		return strings.Join(a.TIDs, " ") < strings.Join(b.TIDs, " ")
+	})
+	jtd.StackGroups = jtd.stackGroups()
+	jtd.Analysis = ar
+	jtd.Problems = make([]string, 0, len(ar.Problems))
+	for _, problem := range ar.Problems {
+		jtd.Problems = append(jtd.Problems, problem.Message)
+	}
+	return ar
+}
+
+//PriorityOutliers returns the threads with a higher OS priority than the most common one among the
+//threads of the same Java priority, sorted by name. The JVM maps each Java priority to an OS priority,
+//a thread above that mapping had its OS priority boosted, which can explain the CPU starvation of the others.
+func (jtd *JavaThreadDump) PriorityOutliers() []*JavaThread {
+	common := jtd.commonOSPrios()
+	res := make([]*JavaThread, 0)
+	for _, jt := range jtd.Threads {
+		if jt.OSPrio > common[jt.Prio] {
+			res = append(res, jt)
+		}
+	}
+	sortThreadsByName(res)
+	return res
+}
+
+//commonOSPrios maps each Java priority to the most common OS priority of its threads, the lowest one on ties.
+func (jtd *JavaThreadDump) commonOSPrios() map[int]int {
+	counts := make(map[int]map[int]int)
+	for _, jt := range jtd.Threads {
+		if counts[jt.Prio] == nil {
+			counts[jt.Prio] = make(map[int]int)
+		}
+		counts[jt.Prio][jt.OSPrio]++
+	}
+	common := make(map[int]int, len(counts))
+	for javaPrio, osPrios := range counts {
+		osPrio, max := 0, 0
+		for prio, count := range osPrios {
+			if count > max || (count == max && prio < osPrio) {
+				osPrio, max = prio, count
+			}
+		}
+		common[javaPrio] = osPrio
+	}
+	return common
+}
+
+//referenceQueueClasses are the reference queues the idle "Finalizer" thread waits on, in ReferenceQueue.remove
+//or, since JDK 19, NativeReferenceQueue.remove and ReferenceQueue.remove0.
+var referenceQueueClasses = map[string]bool{"java.lang.ref.ReferenceQueue": true, "java.lang.ref.NativeReferenceQueue": true}
+
+//finalizationBacklog reports whether jt is one of the JVM finalization threads and is busy. When idle,
+//the "Finalizer" thread waits on the finalizer reference queue, and the "Reference Handler" waits for
+//pending references, RUNNABLE in a native method since JDK 9.
+func (jt *JavaThread) finalizationBacklog() bool {
+	switch jt.Name {
+	case "Finalizer":
+		if jt.Status != StatusWaiting && jt.Status != StatusTimedWaiting {
+			return true
+		}
+		for _, stackLine := range jt.Stack {
+			if !strings.HasPrefix(stackLine, "\tat ") {
+				continue
+			}
+			if f := ParseFrame(stackLine); referenceQueueClasses[f.Class] && strings.HasPrefix(f.Method, "remove") {
+				return false
+			}
+		}
+		return jt.StackDepth > 0
+	case "Reference Handler":
+		return jt.Status == StatusBlocked
+	}
+	return false
+}
+
+//classInitTopFrames is how deep in the stack classInitFrame looks for class initialization frames.
+const classInitTopFrames = 10
+
+//classInitFrames are the frames of a thread initializing a class, or waiting for another thread to do it.
+var classInitFrames = []string{".<clinit>(", "java.lang.Class.forName", "ensureClassInitialized", "ensureInitialized"}
+
+//classInitFrame reports whether the thread is stuck on a class initialization: it either waits for the
+//class initialization monitor (JDK 21+), or waits for a lock with a class initialization top frame.
+func (jt *JavaThread) classInitFrame() (string, bool) {
+	depth := 0
+	for _, stackLine := range jt.Stack {
+		if strings.HasPrefix(stackLine, "\t- waiting on the Class initialization monitor for ") {
+			return strings.TrimPrefix(stackLine, "\t- waiting on the Class initialization monitor for "), true
+		}
+		if !strings.HasPrefix(stackLine, "\tat ") || len(jt.LocksWaiting) == 0 {
+			continue
+		}
+		if depth++; depth > classInitTopFrames {
+			break
+		}
+		for _, initFrame := range classInitFrames {
+			if strings.Contains(stackLine, initFrame) {
+				return stackLine[4:], true
+			}
+		}
+	}
+	return "", false
+}
+
+//gcThreadPrefixes are the name prefixes of the garbage collector threads of the HotSpot collectors.
+var gcThreadPrefixes = []string{"GC task thread", "GC Thread", "G1 ", "Gang worker", "ParGC Thread", "Concurrent Mark-Sweep GC Thread", "ZGC ", "Shenandoah "}
+
+func isGCThread(name string) bool {
+	for _, prefix := range gcThreadPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//jvmThreadsWithoutFrames are the JVM threads that never run Java code, so they always have an empty stack.
+var jvmThreadsWithoutFrames = []string{"Attach Listener", "Signal Dispatcher", "Service Thread", "Sweeper thread", "Notification Thread", "Monitor Deflation Thread", "VM Thread", "VM Periodic Task Thread"}
+
+func hasNoJavaFrames(name string) bool {
+	for _, jvmName := range jvmThreadsWithoutFrames {
+		if name == jvmName {
+			return true
+		}
+	}
+	return isGCThread(name) || isCompilerThread(name)
+}
+
+//isCompilerThread matches the JIT compiler threads, like "C1 CompilerThread0" or "C2 CompilerThread1".
+func isCompilerThread(name string) bool {
+	return strings.Contains(name, "CompilerThread")
+}
@@ -0,0 +1,126 @@
+package jstackparser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPriorityOutliers(t *testing.T) {
+	// The Windows mapping of the Java priorities: 5 to os_prio 0, 8 to 1 and 10 to 2.
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"worker-2" #22 prio=5 os_prio=2 tid=0x0000000018c3c000 nid=0x2c3c runnable [0x000000001a3fe000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Worker.run(Worker.java:12)
+
+"worker-1" #21 prio=5 os_prio=0 tid=0x0000000018c3b000 nid=0x2c3b runnable [0x000000001a2fe000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Worker.run(Worker.java:12)
+
+"Finalizer" #3 daemon prio=8 os_prio=1 tid=0x0000000017c3a000 nid=0x2c38 in Object.wait() [0x000000001a1fe000]
+   java.lang.Thread.State: WAITING (on object monitor)
+	at java.lang.Object.wait(Native Method)
+	- waiting on <0x00000000c0a1b300> (a java.lang.ref.ReferenceQueue$Lock)
+	at java.lang.ref.ReferenceQueue.remove(ReferenceQueue.java:144)
+	- locked <0x00000000c0a1b300> (a java.lang.ref.ReferenceQueue$Lock)
+	at java.lang.ref.Finalizer$FinalizerThread.run(Finalizer.java:216)
+
+"Reference Handler" #2 daemon prio=10 os_prio=2 tid=0x0000000017c39000 nid=0x2c34 in Object.wait() [0x000000001a0fe000]
+   java.lang.Thread.State: WAITING (on object monitor)
+	at java.lang.Object.wait(Native Method)
+	- waiting on <0x00000000c0a1b400> (a java.lang.ref.Reference$Lock)
+	at java.lang.ref.Reference.tryHandlePending(Reference.java:191)
+	- locked <0x00000000c0a1b400> (a java.lang.ref.Reference$Lock)
+	at java.lang.ref.Reference$ReferenceHandler.run(Reference.java:153)
+
+"main" #1 prio=5 os_prio=0 tid=0x0000000002b3e000 nid=0x2b3e runnable [0x00000000029fe000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Main.main(Main.java:5)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outliers := jtd.PriorityOutliers()
+	if len(outliers) != 1 || outliers[0].Name != "worker-2" {
+		t.Fatalf("PriorityOutliers() = %v, want only worker-2", outliers)
+	}
+	if problems := jtd.Analysis.ByCategory(CategoryPriority); len(problems) != 1 || problems[0].TIDs[0] != outliers[0].TID {
+		t.Errorf("priority problems = %v, want only worker-2", problems)
+	}
+}
+
+func TestFinalizationBacklog(t *testing.T) {
+	tests := []struct {
+		name string
+		jt   JavaThread
+		want bool
+	}{
+		{"JDK 8 idle", JavaThread{Name: "Finalizer", Status: StatusWaiting, Stack: []string{
+			"\tat java.lang.Object.wait(Native Method)",
+			"\tat java.lang.ref.ReferenceQueue.remove(ReferenceQueue.java:144)",
+			"\tat java.lang.ref.Finalizer$FinalizerThread.run(Finalizer.java:216)",
+		}}, false},
+		{"JDK 21 idle", JavaThread{Name: "Finalizer", Status: StatusWaiting, Stack: []string{
+			"\tat java.lang.Object.wait0(java.base@21/Native Method)",
+			"\tat java.lang.Object.wait(java.base@21/Object.java:366)",
+			"\tat java.lang.ref.NativeReferenceQueue.await(java.base@21/NativeReferenceQueue.java:48)",
+			"\tat java.lang.ref.ReferenceQueue.remove0(java.base@21/ReferenceQueue.java:158)",
+			"\tat java.lang.ref.NativeReferenceQueue.remove(java.base@21/NativeReferenceQueue.java:89)",
+			"\tat java.lang.ref.Finalizer$FinalizerThread.run(java.base@21/Finalizer.java:173)",
+		}}, false},
+		{"in a finalizer", JavaThread{Name: "Finalizer", Status: StatusRunnable, Stack: []string{
+			"\tat com.acme.Resource.finalize(Resource.java:31)",
+			"\tat java.lang.ref.Finalizer$FinalizerThread.run(java.base@21/Finalizer.java:173)",
+		}}, true},
+	}
+	for _, tt := range tests {
+		tt.jt.analyze()
+		if got := tt.jt.finalizationBacklog(); got != tt.want {
+			t.Errorf("%s: finalizationBacklog() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOptInThresholds(t *testing.T) {
+	// A healthy Tomcat: the idle executor threads own the locks of their queue entries.
+	var sb strings.Builder
+	sb.WriteString("2019-08-20 10:37:05\nFull thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):\n\n")
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&sb, "\"http-nio-8080-exec-%d\" #%d daemon prio=5 os_prio=0 tid=0x00007f5c7c4b%04x nid=0x%x waiting on condition [0x00007f5c2a7f6000]\n", i, 20+i, i, 0x6b00+i)
+		sb.WriteString("   java.lang.Thread.State: WAITING (parking)\n\tat sun.misc.Unsafe.park(Native Method)\n")
+		for l := 0; l < 2; l++ {
+			fmt.Fprintf(&sb, "\t- locked <0x00000000c0a1%02x%02x> (a java.lang.Object)\n", i, l)
+		}
+		sb.WriteString("\tat org.apache.tomcat.util.threads.TaskQueue.take(TaskQueue.java:103)\n\n")
+	}
+	sb.WriteString("\"GC task thread#0 (ParallelGC)\" os_prio=0 tid=0x00007f5c7c01f000 nid=0x6b80 runnable \n\nJNI global references: 12\n")
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		want       []string
+	}{
+		{"defaults", Thresholds{}, []string{}},
+		{"opted in", Thresholds{ManyLocksOwned: 2, GCThreadStorm: 1, IdlePoolRatio: 0.9}, []string{CategoryIdlePool, CategoryGCThreads, CategoryManyLocks}},
+	}
+	for _, tt := range tests {
+		jtd, err := ParseJStack(sb.String(), WithThresholds(tt.thresholds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make([]string, 0)
+		for _, category := range []string{CategoryIdlePool, CategoryGCThreads, CategoryManyLocks} {
+			if len(jtd.Analysis.ByCategory(category)) > 0 {
+				got = append(got, category)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems of categories %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
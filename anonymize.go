@@ -0,0 +1,85 @@
+package jstackparser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var reAddress = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+
+//anonymizer hands out the pseudonyms of the addresses, numbered by kind in the order they are met.
+type anonymizer struct {
+	pseudonyms map[string]string
+	counts     map[string]int
+}
+
+func (a *anonymizer) pseudonym(kind, addr string) string {
+	if addr == "" {
+		return ""
+	}
+	if p, ok := a.pseudonyms[addr]; ok {
+		return p
+	}
+	a.counts[kind]++
+	p := fmt.Sprintf("%s#%d", kind, a.counts[kind])
+	a.pseudonyms[addr] = p
+	return p
+}
+
+func (a *anonymizer) locks(locks []string) {
+	for i, lock := range locks {
+		locks[i] = a.pseudonym("lock", lock)
+	}
+}
+
+//threads anonymizes the threads of jts, sorted by name so that the pseudonyms don't depend on the map order,
+//and returns them keyed by their new tid.
+func (a *anonymizer) threads(jts map[string]*JavaThread) map[string]*JavaThread {
+	sorted := make([]*JavaThread, 0, len(jts))
+	for _, jt := range jts {
+		sorted = append(sorted, jt)
+	}
+	sortThreadsByName(sorted)
+	threads := make(map[string]*JavaThread, len(sorted))
+	for _, jt := range sorted {
+		jt.TID = a.pseudonym("tid", jt.TID)
+		jt.NID = a.pseudonym("nid", jt.NID)
+		jt.ThreadID, jt.NativeID = 0, 0
+		jt.LastSP = a.pseudonym("addr", jt.LastSP)
+		for _, locks := range [][]string{jt.LocksOwned, jt.LocksWaiting, jt.WaitingOn, jt.LocksReacquiring, jt.ParkingOn, jt.OwnableSynchronizers} {
+			a.locks(locks)
+		}
+		for i, stackLine := range jt.Stack {
+			if strings.HasPrefix(stackLine, "\t- ") {
+				jt.Stack[i] = reAddress.ReplaceAllStringFunc(stackLine, func(addr string) string { return a.pseudonym("lock", addr) })
+			}
+		}
+		threads[jt.TID] = jt
+	}
+	return threads
+}
+
+//AnonymizeAddresses replaces the tids, nids and lock addresses with pseudonyms like "tid#1", "nid#1"
+//and "lock#1", the same address getting the same pseudonym everywhere in the dump, and reanalyzes it.
+//The structure, thread names and classes are kept, so the dump can be shared and compared without
+//the pointers of a specific run. ThreadID and NativeID, decoded from the tid and the nid, are reset. LastSP and the other
+//addresses of the retained raw output become "addr#N".
+func (jtd *JavaThreadDump) AnonymizeAddresses() AnalysisResult {
+	a := &anonymizer{pseudonyms: make(map[string]string), counts: make(map[string]int)}
+	jtd.Threads = a.threads(jtd.Threads)
+	if jtd.SystemThreads != nil {
+		jtd.SystemThreads = a.threads(jtd.SystemThreads)
+	}
+	for _, report := range jtd.DeadlockReports {
+		for i := range report.Links {
+			link := &report.Links[i]
+			link.Lock = a.pseudonym("lock", link.Lock)
+			link.Waiting = reAddress.ReplaceAllStringFunc(link.Waiting, func(addr string) string { return a.pseudonym("addr", addr) })
+		}
+	}
+	if jtd.raw != "" {
+		jtd.raw = reAddress.ReplaceAllStringFunc(jtd.raw, func(addr string) string { return a.pseudonym("addr", addr) })
+	}
+	return jtd.Analyze()
+}
@@ -0,0 +1,77 @@
+package jstackparser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+//DeadlockReport is a "Found one Java-level deadlock:" section printed by the JVM after the threads.
+//The JVM also detects the deadlocks on ownable synchronizers, that the lock lines don't show.
+type DeadlockReport struct {
+	//Threads are the names of the deadlocked threads, in the order of the report.
+	Threads []string `json:"threads"`
+	//Links are what each thread waits for and which thread holds it, in the same order.
+	Links []DeadlockLink `json:"links"`
+}
+
+//DeadlockLink is a thread of a DeadlockReport.
+type DeadlockLink struct {
+	Thread string `json:"thread"`
+	//Waiting is the JVM description, like "waiting to lock monitor 0x00007f5c3c003828 (object 0x00000000c0a1b2d0, a java.lang.Object)".
+	Waiting string `json:"waiting"`
+	//Lock is the address of the object, or of the ownable synchronizer, the thread waits for.
+	Lock string `json:"lock"`
+	//HeldBy is the name of the thread holding Lock.
+	HeldBy string `json:"heldBy"`
+}
+
+var (
+	prefixDeadlockReport = []byte(prefixDeadlockSection)
+	prefixWhichIsHeldBy  = []byte("which is held by ")
+	reDeadlockObject     = regexp.MustCompile(`\(object (0x[0-9a-fA-F]+)`)
+)
+
+//deadlockParser collects the deadlock reports of the footer of a dump.
+type deadlockParser struct {
+	reports []DeadlockReport
+	// inReport is set from the "Found one Java-level deadlock:" line to the blank line ending the thread list.
+	inReport bool
+}
+
+//parseLine parses a line of the footer and returns whether it belongs to a deadlock report.
+func (p *deadlockParser) parseLine(b []byte) bool {
+	if bytes.HasPrefix(b, prefixDeadlockReport) {
+		p.reports = append(p.reports, DeadlockReport{Threads: make([]string, 0), Links: make([]DeadlockLink, 0)})
+		p.inReport = true
+		return true
+	}
+	if !p.inReport {
+		return false
+	}
+	line := strings.TrimSpace(string(b))
+	report := &p.reports[len(p.reports)-1]
+	switch {
+	case line == "":
+		p.inReport = false
+	case strings.HasPrefix(line, "="):
+	case strings.HasPrefix(line, `"`) && strings.HasSuffix(line, `":`):
+		name := line[1 : len(line)-2]
+		report.Threads = append(report.Threads, name)
+		report.Links = append(report.Links, DeadlockLink{Thread: name})
+	case len(report.Links) == 0:
+		return false
+	case strings.HasPrefix(line, string(prefixWhichIsHeldBy)):
+		report.Links[len(report.Links)-1].HeldBy = strings.Trim(line[len(prefixWhichIsHeldBy):], `"`)
+	default:
+		link := &report.Links[len(report.Links)-1]
+		link.Waiting = strings.TrimSuffix(line, ",")
+		// The object of a monitor, the address of an ownable synchronizer.
+		if res := reDeadlockObject.FindStringSubmatch(line); res != nil {
+			link.Lock = res[1]
+		} else {
+			link.Lock = reAddress.FindString(line)
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package jstackparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeadlockReports(t *testing.T) {
+	tests := []struct {
+		fixture string
+		threads int
+		want    []DeadlockReport
+	}{
+		{"jdk8.txt", 6, []DeadlockReport{{
+			Threads: []string{"http-nio-8080-exec-2", "http-nio-8080-exec-1"},
+			Links: []DeadlockLink{
+				{"http-nio-8080-exec-2", "waiting to lock monitor 0x00007f5c3c003828 (object 0x00000000c0a1b2d0, a java.lang.Object)", "0x00000000c0a1b2d0", "http-nio-8080-exec-1"},
+				{"http-nio-8080-exec-1", "waiting to lock monitor 0x00007f5c3c006168 (object 0x00000000c0a1b2c8, a java.lang.Object)", "0x00000000c0a1b2c8", "http-nio-8080-exec-2"},
+			},
+		}}},
+		// A monitor deadlock, then one on ownable synchronizers that the lock lines don't show as owned.
+		{"deadlocks.txt", 4, []DeadlockReport{{
+			Threads: []string{"transfer-2", "transfer-1"},
+			Links: []DeadlockLink{
+				{"transfer-2", "waiting to lock monitor 0x00007f5c3c003828 (object 0x00000000c0a1b2d0, a com.acme.Account)", "0x00000000c0a1b2d0", "transfer-1"},
+				{"transfer-1", "waiting to lock monitor 0x00007f5c3c006168 (object 0x00000000c0a1b2c8, a com.acme.Account)", "0x00000000c0a1b2c8", "transfer-2"},
+			},
+		}, {
+			Threads: []string{"cache-2", "cache-1"},
+			Links: []DeadlockLink{
+				{"cache-2", "waiting for ownable synchronizer 0x000000076ab8f0a8, (a java.util.concurrent.locks.ReentrantLock$NonfairSync)", "0x000000076ab8f0a8", "cache-1"},
+				{"cache-1", "waiting for ownable synchronizer 0x000000076ab8f0d8, (a java.util.concurrent.locks.ReentrantLock$NonfairSync)", "0x000000076ab8f0d8", "cache-2"},
+			},
+		}}},
+	}
+	for _, tt := range tests {
+		jtd, err := ParseJStack(readFixture(t, tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(jtd.DeadlockReports, tt.want) {
+			t.Errorf("%s: DeadlockReports = %+v, want %+v", tt.fixture, jtd.DeadlockReports, tt.want)
+		}
+		// The stacks repeated by the reports aren't threads.
+		if len(jtd.Threads) != tt.threads {
+			t.Errorf("%s: %d threads, want %d", tt.fixture, len(jtd.Threads), tt.threads)
+		}
+	}
+}
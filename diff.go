@@ -0,0 +1,177 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//StatusTransitions returns the threads, by name, whose status changed from dump a to dump b,
+//with their status in a and in b. Threads are matched by name since tids can be recycled,
+//so the names shared by several threads of a dump are left out.
+func StatusTransitions(a, b *JavaThreadDump) map[string][2]string {
+	before, after := statusByName(a), statusByName(b)
+	res := make(map[string][2]string)
+	for name, oldStatus := range before {
+		newStatus, ok := after[name]
+		if ok && oldStatus != "" && newStatus != "" && oldStatus != newStatus {
+			res[name] = [2]string{oldStatus, newStatus}
+		}
+	}
+	return res
+}
+
+//statusByName maps the thread names to their status, "" for the names shared by several threads.
+func statusByName(jtd *JavaThreadDump) map[string]string {
+	res := make(map[string]string, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		if _, ok := res[jt.Name]; ok {
+			res[jt.Name] = ""
+		} else {
+			res[jt.Name] = jt.Status
+		}
+	}
+	return res
+}
+
+//Compare returns a text comparison of the dumps a and b, in the spirit of a unified diff: the threads
+//removed ("-") and added ("+") by name, the status changes as a "-" line with the old status followed
+//by a "+" line with the new one, and the stacks that disappeared or appeared with their thread count
+//and a representative thread. Each section is sorted and left out when empty.
+func Compare(a, b *JavaThreadDump) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", a.Date, b.Date)
+
+	before, after := statusByName(a), statusByName(b)
+	lines := make([]string, 0)
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			lines = append(lines, "- "+name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			lines = append(lines, "+ "+name)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i][2:] != lines[j][2:] {
+			return lines[i][2:] < lines[j][2:]
+		}
+		return lines[i] < lines[j]
+	})
+	writeSection(&sb, "threads", lines)
+
+	transitions := StatusTransitions(a, b)
+	names := make([]string, 0, len(transitions))
+	for name := range transitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	lines = make([]string, 0, 2*len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("- %s %s", name, transitions[name][0]), fmt.Sprintf("+ %s %s", name, transitions[name][1]))
+	}
+	writeSection(&sb, "status", lines)
+
+	lines = append(stackLines("- ", a, b), stackLines("+ ", b, a)...)
+	writeSection(&sb, "stacks", lines)
+	return sb.String()
+}
+
+//stackLines describes the stacks of jtd that other doesn't have, sorted by hash, with prefix.
+func stackLines(prefix string, jtd, other *JavaThreadDump) []string {
+	representatives := jtd.stackRepresentatives()
+	lines := make([]string, 0)
+	for _, hash := range jtd.StackHashes() {
+		if other.ByStack[hash] > 0 {
+			continue
+		}
+		jt := representatives[hash]
+		line := fmt.Sprintf("%s%s %d threads like %s (%s)", prefix, hash, jtd.ByStack[hash], jt.Name, jt.Status)
+		if top := jt.topFrame(); top != "" {
+			line += " at " + top
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func writeSection(sb *strings.Builder, title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "@@ %s @@\n", title)
+	for _, line := range lines {
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+}
+
+//DumpDiff is the difference between two dumps of the same JVM, see Diff.
+type DumpDiff struct {
+	//Appeared are the sorted tids of the threads of b that aren't in a.
+	Appeared []string `json:"appeared"`
+	//Disappeared are the sorted tids of the threads of a that aren't in b.
+	Disappeared []string `json:"disappeared"`
+	//Changed are the threads of both dumps whose status or stack hash changed, sorted by tid.
+	Changed []ThreadChange `json:"changed"`
+	//StackDeltas maps the stack hashes whose thread count changed to the count in b minus the count in a.
+	StackDeltas map[string]int `json:"stackDeltas"`
+}
+
+//ThreadChange is a thread whose status or stack changed between two dumps.
+type ThreadChange struct {
+	TID          string `json:"tid"`
+	Name         string `json:"name"`
+	OldStatus    string `json:"oldStatus"`
+	NewStatus    string `json:"newStatus"`
+	OldStackHash string `json:"oldStackHash"`
+	NewStackHash string `json:"newStackHash"`
+}
+
+//Diff returns the threads that appeared, disappeared or changed from dump a to dump b, and the change
+//of the thread count of each stack. Unlike StatusTransitions the threads are matched by tid: a tid
+//reused by a new thread shows as a change rather than as a thread that disappeared and appeared.
+func Diff(a, b *JavaThreadDump) *DumpDiff {
+	diff := &DumpDiff{
+		Appeared:    make([]string, 0),
+		Disappeared: make([]string, 0),
+		Changed:     make([]ThreadChange, 0),
+		StackDeltas: make(map[string]int),
+	}
+	for tid, old := range a.Threads {
+		jt, ok := b.Threads[tid]
+		if !ok {
+			diff.Disappeared = append(diff.Disappeared, tid)
+		} else if old.Status != jt.Status || old.StackHash != jt.StackHash {
+			diff.Changed = append(diff.Changed, ThreadChange{
+				TID:          tid,
+				Name:         jt.Name,
+				OldStatus:    old.Status,
+				NewStatus:    jt.Status,
+				OldStackHash: old.StackHash,
+				NewStackHash: jt.StackHash,
+			})
+		}
+	}
+	for tid := range b.Threads {
+		if _, ok := a.Threads[tid]; !ok {
+			diff.Appeared = append(diff.Appeared, tid)
+		}
+	}
+	sort.Strings(diff.Appeared)
+	sort.Strings(diff.Disappeared)
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].TID < diff.Changed[j].TID })
+	for hash, count := range a.ByStack {
+		if delta := b.ByStack[hash] - count; delta != 0 {
+			diff.StackDeltas[hash] = delta
+		}
+	}
+	for hash, count := range b.ByStack {
+		if _, ok := a.ByStack[hash]; !ok {
+			diff.StackDeltas[hash] = count
+		}
+	}
+	return diff
+}
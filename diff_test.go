@@ -0,0 +1,67 @@
+package jstackparser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//diffDump builds a dump with the given threads, a "name tid status frame" line each, and idle
+//pool threads sharing a stack.
+func diffDump(t *testing.T, idle int, threads ...string) *JavaThreadDump {
+	t.Helper()
+	var sb strings.Builder
+	sb.WriteString("2019-08-20 10:37:05\nFull thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):\n\n")
+	for i, thread := range threads {
+		f := strings.Fields(thread)
+		fmt.Fprintf(&sb, "\"%s\" #%d prio=5 os_prio=0 tid=%s nid=0x%x runnable [0x00007f5c2a7f6000]\n", f[0], i+1, f[1], 0x6b00+i)
+		fmt.Fprintf(&sb, "   java.lang.Thread.State: %s\n\tat %s\n\n", f[2], f[3])
+	}
+	for i := 0; i < idle; i++ {
+		fmt.Fprintf(&sb, "\"pool-1-thread-%d\" #%d prio=5 os_prio=0 tid=0x00007f5c7c5%05x nid=0x%x waiting on condition [0x00007f5c2a7f6000]\n", i, 100+i, i, 0x6c00+i)
+		sb.WriteString("   java.lang.Thread.State: WAITING (parking)\n\tat sun.misc.Unsafe.park(Native Method)\n\tat java.util.concurrent.ThreadPoolExecutor.getTask(ThreadPoolExecutor.java:1074)\n\n")
+	}
+	sb.WriteString("JNI global references: 12\n")
+	jtd, err := ParseJStack(sb.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jtd
+}
+
+func TestDiff(t *testing.T) {
+	a := diffDump(t, 2,
+		"main 0x00007f5c7c00a000 RUNNABLE com.acme.Main.main(Main.java:5)",
+		"worker 0x00007f5c7c4b6000 RUNNABLE com.acme.Worker.run(Worker.java:12)",
+		"gone 0x00007f5c7c4b7000 RUNNABLE com.acme.Gone.run(Gone.java:3)")
+	b := diffDump(t, 5,
+		"main 0x00007f5c7c00a000 RUNNABLE com.acme.Main.main(Main.java:5)",
+		"worker 0x00007f5c7c4b6000 BLOCKED com.acme.Worker.store(Worker.java:40)",
+		"new 0x00007f5c7c4b8000 RUNNABLE com.acme.New.run(New.java:8)")
+	diff := Diff(a, b)
+	if want := []string{"0x00007f5c7c4b8000", "0x00007f5c7c500002", "0x00007f5c7c500003", "0x00007f5c7c500004"}; !reflect.DeepEqual(diff.Appeared, want) {
+		t.Errorf("Appeared = %v, want %v", diff.Appeared, want)
+	}
+	if want := []string{"0x00007f5c7c4b7000"}; !reflect.DeepEqual(diff.Disappeared, want) {
+		t.Errorf("Disappeared = %v, want %v", diff.Disappeared, want)
+	}
+	old, jt := a.Threads["0x00007f5c7c4b6000"], b.Threads["0x00007f5c7c4b6000"]
+	if want := []ThreadChange{{jt.TID, "worker", StatusRunnable, StatusBlocked, old.StackHash, jt.StackHash}}; !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("Changed = %+v, want %+v", diff.Changed, want)
+	}
+	idle := a.Threads["0x00007f5c7c500000"].StackHash
+	want := map[string]int{
+		idle:          3,
+		old.StackHash: -1,
+		jt.StackHash:  1,
+		a.Threads["0x00007f5c7c4b7000"].StackHash: -1,
+		b.Threads["0x00007f5c7c4b8000"].StackHash: 1,
+	}
+	if !reflect.DeepEqual(diff.StackDeltas, want) {
+		t.Errorf("StackDeltas = %v, want %v", diff.StackDeltas, want)
+	}
+	if diff := Diff(a, a); len(diff.Appeared)+len(diff.Disappeared)+len(diff.Changed)+len(diff.StackDeltas) != 0 {
+		t.Errorf("Diff(a, a) = %+v, want no change", diff)
+	}
+}
@@ -0,0 +1,72 @@
+package jstackparser
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ndjsonThread is a thread with the date of its dump, as written by WriteNDJSON.
+type ndjsonThread struct {
+	Date      string    `json:"date"`
+	Timestamp time.Time `json:"timestamp"`
+	*JavaThread
+}
+
+//WriteNDJSON writes the threads to w as JSON Lines, one JSON object per thread with the dump date
+//and timestamp injected. The threads are sorted by name.
+func (jtd *JavaThreadDump) WriteNDJSON(w io.Writer) error {
+	jts := make([]*JavaThread, 0, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		jts = append(jts, jt)
+	}
+	sortThreadsByName(jts)
+	enc := json.NewEncoder(w)
+	for _, jt := range jts {
+		if err := enc.Encode(ndjsonThread{Date: jtd.Date, Timestamp: jtd.Timestamp, JavaThread: jt}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//StatusCSV returns the number of threads of each status from ByStatus as CSV, with a "status,count"
+//header and the rows sorted by status.
+func (jtd *JavaThreadDump) StatusCSV() string {
+	statuses := make([]string, 0, len(jtd.ByStatus))
+	for status := range jtd.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"status", "count"})
+	for _, status := range statuses {
+		w.Write([]string{status, strconv.Itoa(jtd.ByStatus[status])})
+	}
+	w.Flush()
+	return sb.String()
+}
+
+//ThreadsCSV returns a CSV row per thread with its name, tid, nid, status, stack depth and stack hash,
+//after a header row. The rows are sorted by name and the fields quoted when needed, like the names
+//with commas.
+func (jtd *JavaThreadDump) ThreadsCSV() string {
+	jts := make([]*JavaThread, 0, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		jts = append(jts, jt)
+	}
+	sortThreadsByName(jts)
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"name", "tid", "nid", "status", "stackDepth", "stackHash"})
+	for _, jt := range jts {
+		w.Write([]string{jt.Name, jt.TID, jt.NID, jt.Status, strconv.Itoa(jt.StackDepth), jt.StackHash})
+	}
+	w.Flush()
+	return sb.String()
+}
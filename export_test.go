@@ -0,0 +1,49 @@
+package jstackparser
+
+import (
+	"encoding/csv"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSV(t *testing.T) {
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"worker, \"b\"" #22 prio=5 os_prio=0 tid=0x00007f5c7c4b7000 nid=0x6b9f waiting on condition [0x00007f5c2a6f5000]
+   java.lang.Thread.State: WAITING (parking)
+	at sun.misc.Unsafe.park(Native Method)
+	at com.acme.Worker.run(Worker.java:12)
+
+"worker, a" #21 prio=5 os_prio=0 tid=0x00007f5c7c4b6000 nid=0x6b9e runnable [0x00007f5c2a7f6000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Worker.run(Worker.java:12)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := jtd.StatusCSV(), "status,count\nRUNNABLE,1\nWAITING,1\n"; got != want {
+		t.Errorf("StatusCSV() = %q, want %q", got, want)
+	}
+	csvThreads := jtd.ThreadsCSV()
+	if !strings.Contains(csvThreads, "\n\"worker, \"\"b\"\"\",0x00007f5c7c4b7000,") {
+		t.Errorf("ThreadsCSV() = %q, want the names quoted", csvThreads)
+	}
+	records, err := csv.NewReader(strings.NewReader(csvThreads)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := jtd.Threads["0x00007f5c7c4b6000"], jtd.Threads["0x00007f5c7c4b7000"]
+	want := [][]string{
+		{"name", "tid", "nid", "status", "stackDepth", "stackHash"},
+		{"worker, \"b\"", b.TID, "0x6b9f", StatusWaiting, "2", b.StackHash},
+		{"worker, a", a.TID, "0x6b9e", StatusRunnable, "1", a.StackHash},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("ThreadsCSV() records = %q, want %q", records, want)
+	}
+}
@@ -0,0 +1,95 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const prefixDeadlockSection = "Found one Java-level deadlock:"
+
+//ExtractJStacks returns the jstack outputs found in s, like a log file where the JVM printed them on SIGQUIT
+//among other lines. Each dump starts at its date line, or at the "Full thread dump" line when it has none.
+//It ends with its "JNI global references:" line, or the deadlock report that follows it, at the start of
+//the next dump or at the end of s.
+func ExtractJStacks(s string) []string {
+	dumps := make([]string, 0)
+	// start is the offset of the current dump, end the offset after its last line once the footer is reached.
+	start, end, prevLine, offset := -1, -1, -1, 0
+	inDeadlock := false
+	for offset < len(s) {
+		next := len(s)
+		if i := strings.IndexByte(s[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		line := strings.TrimRight(s[offset:next], "\r\n")
+		if strings.HasPrefix(line, string(prefixFullThreadDump)) {
+			begin := offset
+			if prevLine >= 0 {
+				if _, err := time.Parse(dateLayout, strings.TrimSpace(s[prevLine:offset])); err == nil {
+					begin = prevLine
+				}
+			}
+			if end >= 0 {
+				dumps = append(dumps, s[start:end])
+			} else if start >= 0 && start < begin {
+				dumps = append(dumps, s[start:begin])
+			}
+			start, end, inDeadlock = begin, -1, false
+		} else if start < 0 {
+			// Not in a dump.
+		} else if end < 0 {
+			if strings.HasPrefix(line, string(prefixJNIGlobalRefs)) || strings.HasPrefix(line, string(prefixJNIGlobalRefsJDK11)) {
+				end = next
+			}
+		} else if inDeadlock {
+			if strings.HasPrefix(line, "Found ") && (strings.HasSuffix(line, " deadlock.") || strings.HasSuffix(line, " deadlocks.")) {
+				end, inDeadlock = next, false
+			}
+		} else if strings.HasPrefix(line, prefixDeadlockSection) {
+			inDeadlock = true
+		} else if strings.TrimSpace(line) != "" {
+			dumps = append(dumps, s[start:end])
+			start, end = -1, -1
+		}
+		prevLine = offset
+		offset = next
+	}
+	if end >= 0 && !inDeadlock {
+		dumps = append(dumps, s[start:end])
+	} else if start >= 0 {
+		dumps = append(dumps, s[start:])
+	}
+	return dumps
+}
+
+//ParseJStackMulti parses the dumps of s, like several jstack outputs concatenated in a file, and
+//returns them sorted by their Timestamp. The dumps are split like ExtractJStacks does, so each one
+//gets the date line before its "Full thread dump" line. It fails when s has no valid dump, otherwise
+//the dumps that can't be parsed are skipped and the returned error lists them.
+func ParseJStackMulti(s string, options ...Option) ([]*JavaThreadDump, error) {
+	opts := newOptions(options)
+	blocks := ExtractJStacks(s)
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("couldn't find a valid java jstack output")
+	}
+	dumps := make([]*JavaThreadDump, 0, len(blocks))
+	failures := make([]string, 0)
+	for i, block := range blocks {
+		jtd, err := ParseJStackWithOptions(block, opts)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("dump %d: %v", i+1, err))
+			continue
+		}
+		dumps = append(dumps, jtd)
+	}
+	sort.SliceStable(dumps, func(i, j int) bool { return dumps[i].Timestamp.Before(dumps[j].Timestamp) })
+	if len(dumps) == 0 {
+		return dumps, fmt.Errorf("couldn't parse any of the %d dumps: %s", len(blocks), strings.Join(failures, "; "))
+	}
+	if len(failures) > 0 {
+		return dumps, fmt.Errorf("couldn't parse %d of %d dumps: %s", len(failures), len(blocks), strings.Join(failures, "; "))
+	}
+	return dumps, nil
+}
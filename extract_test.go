@@ -0,0 +1,57 @@
+package jstackparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+const (
+	extractThreads = "Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):\n\n" +
+		"\"main\" #1 prio=5 os_prio=0 tid=0x00007f5c7c00a000 nid=0x6b7f runnable [0x00007f5c84e1e000]\n" +
+		"   java.lang.Thread.State: RUNNABLE\n" +
+		"\tat com.acme.Main.main(Main.java:5)\n\n"
+	extractFooter   = "JNI global references: 12\n"
+	extractDeadlock = "\n\nFound one Java-level deadlock:\n=============================\n" +
+		"\"worker-2\":\n  waiting to lock monitor 0x00007f5c3c003828 (object 0x00000000c0a1b2d0, a java.lang.Object),\n  which is held by \"worker-1\"\n\n" +
+		"Java stack information for the threads listed above:\n===================================================\n" +
+		"\"worker-2\":\n\tat com.acme.Service.doOther(Service.java:57)\n\nFound 1 deadlock.\n"
+	// extractDump1 was taken after extractDump2.
+	extractDump1 = "2019-08-20 10:42:05\n" + extractThreads + extractFooter
+	extractDump2 = "2019-08-20 10:37:05\n" + extractThreads + extractFooter
+	extractLog   = "10:37:06.123 INFO  [main] c.a.Server - request served\n"
+)
+
+func TestExtractJStacks(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want []string
+	}{
+		{"whole input", extractDump1, []string{extractDump1}},
+		{"log around and between", extractLog + extractDump1 + extractLog + "\n" + extractDump2 + extractLog, []string{extractDump1, extractDump2}},
+		{"no date", extractLog + extractThreads + extractFooter + extractLog, []string{extractThreads + extractFooter}},
+		{"deadlock report", extractDump1 + extractDeadlock + extractLog, []string{extractDump1 + extractDeadlock}},
+		// Without footer, the dump only ends at the next one or the end of s.
+		{"no footer", extractLog + "2019-08-20 10:42:05\n" + extractThreads + extractDump2, []string{"2019-08-20 10:42:05\n" + extractThreads, extractDump2}},
+		{"no footer at the end", extractDump2 + extractThreads + extractLog, []string{extractDump2, extractThreads + extractLog}},
+		{"no dump", extractLog + extractLog, []string{}},
+	}
+	for _, tt := range tests {
+		if got := ExtractJStacks(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ExtractJStacks() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseJStackMulti(t *testing.T) {
+	dumps, err := ParseJStackMulti(extractLog + extractDump1 + extractLog + extractDump2 + extractLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) != 2 || dumps[0].Date != "2019-08-20 10:37:05" || dumps[1].Date != "2019-08-20 10:42:05" {
+		t.Fatalf("ParseJStackMulti() gave %d dumps, want the two dumps sorted by date", len(dumps))
+	}
+	if dumps[0].TotalThreads != 1 || dumps[1].TotalThreads != 1 {
+		t.Errorf("TotalThreads = %d and %d, want 1 and 1", dumps[0].TotalThreads, dumps[1].TotalThreads)
+	}
+}
@@ -0,0 +1,52 @@
+package jstackparser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+//ParseJStackFiles parses the jstack outputs stored in paths, which can be gzip compressed,
+//and returns the dumps sorted by their Timestamp. Files that can't be parsed are skipped,
+//the returned error lists them.
+func ParseJStackFiles(paths []string, options ...Option) ([]*JavaThreadDump, error) {
+	opts := newOptions(options)
+	dumps := make([]*JavaThreadDump, 0, len(paths))
+	failures := make([]string, 0)
+	for _, path := range paths {
+		jtd, err := parseJStackFile(path, opts)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", path, err))
+			continue
+		}
+		dumps = append(dumps, jtd)
+	}
+	sort.SliceStable(dumps, func(i, j int) bool { return dumps[i].Timestamp.Before(dumps[j].Timestamp) })
+	if len(failures) > 0 {
+		return dumps, fmt.Errorf("couldn't parse %d of %d files: %s", len(failures), len(paths), strings.Join(failures, "; "))
+	}
+	return dumps, nil
+}
+
+func parseJStackFile(path string, opts Options) (*JavaThreadDump, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return parseJStack(r, opts, nil)
+}
@@ -0,0 +1,86 @@
+package jstackparser
+
+import (
+	"strconv"
+	"strings"
+)
+
+//Frame is a decomposed "at" line of a stack like "at java.lang.Object.wait(java.base@17.0.1/Native Method)",
+//"at java.base@17.0.1/java.lang.Object.wait(Native Method)" or "at com.acme.Service.doWork(Service.java:42)".
+type Frame struct {
+	ClassLoader   string `json:"classLoader,omitempty"`
+	Module        string `json:"module,omitempty"`
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+	Class         string `json:"class"`
+	Method        string `json:"method"`
+	Source        string `json:"source,omitempty"`
+	Line          int    `json:"line,omitempty"`
+}
+
+//ParseFrame decomposes a stack frame, with or without the leading "\tat ".
+//It understands the legacy format and the JDK 9+ "classloader/module@version/" qualified ones, with the
+//qualifier before the class like StackTraceElement.toString prints it, or in the location like jstack does.
+func ParseFrame(line string) Frame {
+	var f Frame
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "at ")
+	if i := strings.IndexByte(line, '('); i >= 0 {
+		location := strings.TrimSuffix(line[i+1:], ")")
+		line = line[:i]
+		// jstack prints the module in the location, "(java.base@17/Object.java:366)".
+		if j := strings.LastIndexByte(location, '/'); j >= 0 {
+			f.setQualifier(location[:j])
+			location = location[j+1:]
+		}
+		f.Source = location
+		if j := strings.LastIndexByte(location, ':'); j >= 0 {
+			if n, err := strconv.Atoi(location[j+1:]); err == nil {
+				f.Source = location[:j]
+				f.Line = n
+			}
+		}
+	}
+	if i := qualifierEnd(line); i >= 0 {
+		f.setQualifier(line[:i])
+		line = line[i+1:]
+	}
+	if i := strings.LastIndexByte(line, '.'); i >= 0 {
+		f.Class = line[:i]
+		f.Method = line[i+1:]
+	} else {
+		f.Method = line
+	}
+	return f
+}
+
+//setQualifier sets the class loader and the module of the frame from qualifier: "loader/module@version",
+//"loader/" for a class in the unnamed module, or just "module@version".
+func (f *Frame) setQualifier(qualifier string) {
+	if j := strings.IndexByte(qualifier, '/'); j >= 0 {
+		f.ClassLoader = qualifier[:j]
+		qualifier = qualifier[j+1:]
+	}
+	if j := strings.IndexByte(qualifier, '@'); j >= 0 {
+		f.ModuleVersion = qualifier[j+1:]
+		qualifier = qualifier[:j]
+	}
+	f.Module = qualifier
+}
+
+//qualifierEnd returns the index of the slash ending the class loader and module qualifier, or -1.
+//Hidden classes like "Foo$$Lambda$14/0x0000000800066840" have a slash in their own name.
+func qualifierEnd(s string) int {
+	i := strings.LastIndexByte(s, '/')
+	for i >= 0 && strings.HasPrefix(s[i+1:], "0x") {
+		i = strings.LastIndexByte(s[:i], '/')
+	}
+	return i
+}
+
+//Package returns the package of the frame class, "" for the default package.
+func (f Frame) Package() string {
+	if i := strings.LastIndexByte(f.Class, '.'); i >= 0 {
+		return f.Class[:i]
+	}
+	return ""
+}
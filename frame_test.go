@@ -0,0 +1,22 @@
+package jstackparser
+
+import "testing"
+
+func TestParseFrame(t *testing.T) {
+	tests := []struct {
+		line string
+		want Frame
+	}{
+		{"\tat com.acme.Service.doWork(Service.java:42)", Frame{Class: "com.acme.Service", Method: "doWork", Source: "Service.java", Line: 42}},
+		{"\tat java.lang.Object.wait(java.base@17.0.1/Object.java:366)", Frame{Module: "java.base", ModuleVersion: "17.0.1", Class: "java.lang.Object", Method: "wait", Source: "Object.java", Line: 366}},
+		{"\tat sun.nio.ch.SocketDispatcher.read0(java.base@17/Native Method)", Frame{Module: "java.base", ModuleVersion: "17", Class: "sun.nio.ch.SocketDispatcher", Method: "read0", Source: "Native Method"}},
+		{"at java.base@17.0.1/java.lang.Object.wait(Native Method)", Frame{Module: "java.base", ModuleVersion: "17.0.1", Class: "java.lang.Object", Method: "wait", Source: "Native Method"}},
+		{"at app//com.acme.Main.main(Main.java:5)", Frame{ClassLoader: "app", Class: "com.acme.Main", Method: "main", Source: "Main.java", Line: 5}},
+		{"\tat com.acme.Main$$Lambda$14/0x0000000800066840.run(Unknown Source)", Frame{Class: "com.acme.Main$$Lambda$14/0x0000000800066840", Method: "run", Source: "Unknown Source"}},
+	}
+	for _, tt := range tests {
+		if got := ParseFrame(tt.line); got != tt.want {
+			t.Errorf("ParseFrame(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,3 @@
 module github.com/adrinicomartin/jstackparser
 
 go 1.12
-
-require github.com/sirupsen/logrus v1.4.2
@@ -0,0 +1,184 @@
+package jstackparser
+
+import "sort"
+
+//The kinds of the nodes and edges of a Graph.
+const (
+	NodeThread = "thread"
+	NodeLock   = "lock"
+	//EdgeOwns goes from a thread to a lock it currently holds.
+	EdgeOwns = "owns"
+	//EdgeWaitsFor goes from a thread to a lock it is waiting to lock, to re-lock after wait() or parked on.
+	EdgeWaitsFor = "waitsFor"
+)
+
+//Node is a thread, identified by its tid, or a lock, identified by its address.
+type Node struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	//Thread is the thread of a NodeThread, nil for a lock.
+	Thread *JavaThread `json:"-"`
+}
+
+//Edge is a typed relation from a thread to a lock.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+//Graph holds the lock relationships of the threads of a dump. Nodes are sorted by kind then id,
+//and Edges by origin, kind and target.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+	// waitsFor maps a tid to the sorted tids of the owners of the locks it waits for, and waitedBy is the reverse.
+	waitsFor map[string][]string
+	waitedBy map[string][]string
+}
+
+//LockGraph returns the graph of the threads and of the locks they own or wait for.
+//Only the locks involved in an edge are nodes, the monitors released in wait() aren't owned.
+func (jtd *JavaThreadDump) LockGraph() *Graph {
+	g := &Graph{Nodes: make([]Node, 0, len(jtd.Threads)), Edges: make([]Edge, 0), waitsFor: make(map[string][]string), waitedBy: make(map[string][]string)}
+	locks := make(map[string]bool)
+	for lock, owner := range jtd.LockOwners {
+		g.Edges = append(g.Edges, Edge{From: owner, To: lock, Kind: EdgeOwns})
+		locks[lock] = true
+	}
+	for tid, jt := range jtd.Threads {
+		g.Nodes = append(g.Nodes, Node{ID: tid, Kind: NodeThread, Thread: jt})
+		owners := make(map[string]bool)
+		for _, waited := range [][]string{jt.LocksWaiting, jt.LocksReacquiring, jt.ParkingOn} {
+			for _, lock := range waited {
+				g.Edges = append(g.Edges, Edge{From: tid, To: lock, Kind: EdgeWaitsFor})
+				locks[lock] = true
+				if owner := jtd.LockOwners[lock]; owner != "" && owner != tid && !owners[owner] {
+					owners[owner] = true
+					g.waitsFor[tid] = append(g.waitsFor[tid], owner)
+					g.waitedBy[owner] = append(g.waitedBy[owner], tid)
+				}
+			}
+		}
+		sort.Strings(g.waitsFor[tid])
+	}
+	for _, waiters := range g.waitedBy {
+		sort.Strings(waiters)
+	}
+	for lock := range locks {
+		g.Nodes = append(g.Nodes, Node{ID: lock, Kind: NodeLock})
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool {
+		if g.Nodes[i].Kind != g.Nodes[j].Kind {
+			return g.Nodes[i].Kind > g.Nodes[j].Kind
+		}
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.To < b.To
+	})
+	return g
+}
+
+//Neighbors returns the sorted tids of the threads owning a lock the thread tid waits for.
+func (g *Graph) Neighbors(tid string) []string {
+	return append([]string{}, g.waitsFor[tid]...)
+}
+
+//WaitingFor returns the sorted tids of the threads waiting for a lock the thread tid owns, the reverse of Neighbors.
+func (g *Graph) WaitingFor(tid string) []string {
+	return append([]string{}, g.waitedBy[tid]...)
+}
+
+//Behind returns the sorted tids of the threads stalled behind the thread tid: waiting for a lock it owns,
+//or for a lock owned by such a thread, and so on. tid itself is left out, even when it is in a deadlock.
+func (g *Graph) Behind(tid string) []string {
+	return g.stalled(g.waitedBy[tid], tid)
+}
+
+//stalled returns the sorted tids of the threads of starts and of the threads transitively waiting for them,
+//without going through the thread skip.
+func (g *Graph) stalled(starts []string, skip string) []string {
+	seen := map[string]bool{skip: true}
+	res := make([]string, 0)
+	queue := append([]string{}, starts...)
+	for len(queue) > 0 {
+		tid := queue[0]
+		queue = queue[1:]
+		if seen[tid] {
+			continue
+		}
+		seen[tid] = true
+		res = append(res, tid)
+		queue = append(queue, g.waitedBy[tid]...)
+	}
+	sort.Strings(res)
+	return res
+}
+
+//Roots returns the sorted tids of the threads other threads wait for but that don't wait for any
+//thread themselves: the heads of the wait chains.
+func (g *Graph) Roots() []string {
+	waitedFor := make(map[string]bool)
+	for _, owners := range g.waitsFor {
+		for _, owner := range owners {
+			waitedFor[owner] = true
+		}
+	}
+	roots := make([]string, 0)
+	for tid := range waitedFor {
+		if len(g.waitsFor[tid]) == 0 {
+			roots = append(roots, tid)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+//Cycles returns the elementary cycles of threads waiting for each other, a deadlock each.
+//A cycle starts with its smallest tid and follows the waits, the cycles are sorted.
+func (g *Graph) Cycles() [][]string {
+	starts := make([]string, 0, len(g.waitsFor))
+	for tid := range g.waitsFor {
+		starts = append(starts, tid)
+	}
+	sort.Strings(starts)
+	cycles := make([][]string, 0)
+	for _, start := range starts {
+		// Only the cycles whose smallest tid is start, so each is found once.
+		path := []string{start}
+		onPath := map[string]bool{start: true}
+		var visit func(tid string)
+		visit = func(tid string) {
+			for _, next := range g.waitsFor[tid] {
+				if next == start {
+					cycles = append(cycles, append([]string{}, path...))
+				} else if next > start && !onPath[next] {
+					path = append(path, next)
+					onPath[next] = true
+					visit(next)
+					onPath[next] = false
+					path = path[:len(path)-1]
+				}
+			}
+		}
+		visit(start)
+	}
+	sort.Slice(cycles, func(i, j int) bool {
+		a, b := cycles[i], cycles[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return cycles
+}
@@ -0,0 +1,125 @@
+package jstackparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//headerFields holds the indexes of the named capture groups of a thread header regexp, -1 when absent.
+type headerFields struct {
+	name, number, daemon, prio, osPrio, cpu, elapsed, tid, nid, status int
+}
+
+func newHeaderFields(rx *regexp.Regexp) headerFields {
+	f := headerFields{-1, -1, -1, -1, -1, -1, -1, -1, -1, -1}
+	for i, groupName := range rx.SubexpNames() {
+		switch groupName {
+		case "name":
+			f.name = i
+		case "number":
+			f.number = i
+		case "daemon":
+			f.daemon = i
+		case "prio":
+			f.prio = i
+		case "osprio":
+			f.osPrio = i
+		case "cpu":
+			f.cpu = i
+		case "elapsed":
+			f.elapsed = i
+		case "tid":
+			f.tid = i
+		case "nid":
+			f.nid = i
+		case "status":
+			f.status = i
+		}
+	}
+	return f
+}
+
+func submatch(res []string, i int) string {
+	if i < 0 || i >= len(res) {
+		return ""
+	}
+	return res[i]
+}
+
+//apply sets the header fields of jt from the submatches of a header line.
+//The returned error reports a nid that couldn't be decoded, the other fields are still set.
+func (f headerFields) apply(jt *JavaThread, res []string) error {
+	jt.Name = strings.Replace(submatch(res, f.name), `\"`, `"`, -1)
+	jt.InternalNumber = submatch(res, f.number)
+	jt.IsDaemon = submatch(res, f.daemon) != ""
+	jt.Prio, _ = strconv.Atoi(submatch(res, f.prio))
+	jt.OSPrio, _ = strconv.Atoi(submatch(res, f.osPrio))
+	jt.CPUTimeMs, _ = strconv.ParseFloat(submatch(res, f.cpu), 64)
+	jt.ElapsedSec, _ = strconv.ParseFloat(submatch(res, f.elapsed), 64)
+	jt.TID = submatch(res, f.tid)
+	jt.NID = submatch(res, f.nid)
+	jt.Status, jt.LastSP = splitLastSP(submatch(res, f.status))
+	jt.ThreadID = parseTID(jt.TID)
+	if jt.NID == "" {
+		return nil
+	}
+	nativeID, err := parseNID(jt.NID)
+	jt.NativeID = nativeID
+	return err
+}
+
+//parseTID decodes a tid, a 0x-prefixed hex pointer, and returns 0 when it can't.
+//The pointers above the int64 range keep their bits.
+func parseTID(tid string) int64 {
+	if !strings.HasPrefix(tid, "0x") && !strings.HasPrefix(tid, "0X") {
+		return 0
+	}
+	id, err := strconv.ParseUint(tid[2:], 16, 64)
+	if err != nil {
+		return 0
+	}
+	return int64(id)
+}
+
+//splitLastSP separates the trailing "[0x...]" stack pointer from the status of a header,
+//"waiting on condition [0x00007f5c3a1fe000]" gives "waiting on condition" and "0x00007f5c3a1fe000".
+func splitLastSP(status string) (string, string) {
+	status = strings.TrimSpace(status)
+	i := strings.LastIndex(status, "[0x")
+	if i < 0 || !strings.HasSuffix(status, "]") {
+		return status, ""
+	}
+	return strings.TrimSpace(status[:i]), status[i+1 : len(status)-1]
+}
+
+//extractGroup removes the group="name" (or group=name) token that some dumps print in the
+//thread header and returns the remaining header and the group name.
+func extractGroup(header string) (string, string) {
+	loc := reGroup.FindStringSubmatchIndex(header)
+	if loc == nil {
+		return header, ""
+	}
+	group := ""
+	if loc[2] >= 0 {
+		group = header[loc[2]:loc[3]]
+	} else {
+		group = header[loc[4]:loc[5]]
+	}
+	return header[:loc[0]] + header[loc[1]:], group
+}
+
+//ParseThreadHeader parses a thread header line, like a line of a jstack output found with grep, and
+//returns the thread with the header fields set: name, number, daemon, priorities, tid, nid and status.
+//The boolean is false when line isn't a thread header. WithHeaderRegexp selects another header format.
+func ParseThreadHeader(line string, options ...Option) (*JavaThread, bool) {
+	opts := newOptions(options)
+	opts.Strict = false
+	warnings := make([]string, 0)
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil, false
+	}
+	jt, _, _ := newThreadParser(opts, opts.logger(), &warnings).parseLine([]byte(line), 1)
+	return jt, jt != nil
+}
@@ -0,0 +1,59 @@
+package jstackparser
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHeaderRegexp(t *testing.T) {
+	// A vendor format with the fields in another order, the groups are found by name.
+	rx := regexp.MustCompile(`^"(?P<name>[^"]+)" (?P<tid>0x[0-9a-f]+)/(?P<nid>0x[0-9a-f]+) prio:(?P<prio>\d+)(?P<daemon> daemon)? (?P<status>.*)$`)
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"worker" 0x00007f5c7c4b6000/0x6b9e prio:7 daemon waiting on condition [0x00007f5c2a7f6000]
+   java.lang.Thread.State: WAITING (parking)
+	at sun.misc.Unsafe.park(Native Method)
+
+"main" 0x00007f5c7c00a000/0x6b7f prio:5 runnable
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Main.main(Main.java:5)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump, WithHeaderRegexp(rx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tid, name, nid, lastSP string
+		nativeID               int64
+		prio                   int
+		daemon                 bool
+	}{
+		{"0x00007f5c7c4b6000", "worker", "0x6b9e", "0x00007f5c2a7f6000", 0x6b9e, 7, true},
+		{"0x00007f5c7c00a000", "main", "0x6b7f", "", 0x6b7f, 5, false},
+	}
+	for _, tt := range tests {
+		jt := jtd.Threads[tt.tid]
+		if jt == nil {
+			t.Errorf("thread %s %q not captured", tt.tid, tt.name)
+			continue
+		}
+		if jt.Name != tt.name || jt.NID != tt.nid || jt.NativeID != tt.nativeID || jt.Prio != tt.prio || jt.IsDaemon != tt.daemon || jt.LastSP != tt.lastSP {
+			t.Errorf("thread %s = %q nid=%s (%d) prio=%d daemon=%v sp=%s, want %q nid=%s (%d) prio=%d daemon=%v sp=%s", tt.tid,
+				jt.Name, jt.NID, jt.NativeID, jt.Prio, jt.IsDaemon, jt.LastSP, tt.name, tt.nid, tt.nativeID, tt.prio, tt.daemon, tt.lastSP)
+		}
+		// The fields without a group are left unset.
+		if jt.InternalNumber != "" || jt.OSPrio != 0 || jt.CPUTimeMs != 0 {
+			t.Errorf("thread %s has number %q, os_prio %d and cpu %v without their groups", tt.tid, jt.InternalNumber, jt.OSPrio, jt.CPUTimeMs)
+		}
+	}
+	if len(jtd.ParseWarnings) != 0 {
+		t.Errorf("ParseWarnings = %q, want none", jtd.ParseWarnings)
+	}
+	// The built-in regexp doesn't know the format.
+	if jtd, err := ParseJStack(dump); err != nil || jtd.TotalThreads != 0 {
+		t.Errorf("parsing without HeaderRegexp gave %v threads and error %v, want no thread", jtd.TotalThreads, err)
+	}
+}
@@ -1,100 +1,484 @@
+//Package jstackparser parses and analyzes the output of the jstack command.
+//
+//Every output of the package is deterministic: parsing the same input twice gives byte-identical
+//ToJSON output. Maps are serialized with sorted keys, list-valued outputs are sorted (problems and
+//locks lexicographically, threads by name unless documented otherwise), and the slices of a
+//JavaThread keep the order of the dump. New list-valued outputs must follow the same rule.
 package jstackparser
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+)
 
-	log "github.com/sirupsen/logrus"
+//The canonical thread statuses, the java.lang.Thread.State enum constants.
+//JavaThread.Status holds one of them when the dump has a Thread.State line, whatever the JVM locale.
+const (
+	StatusNew          = "NEW"
+	StatusRunnable     = "RUNNABLE"
+	StatusBlocked      = "BLOCKED"
+	StatusWaiting      = "WAITING"
+	StatusTimedWaiting = "TIMED_WAITING"
+	StatusTerminated   = "TERMINATED"
 )
 
-const maxstackdepth = 20
+//StatusBlockedNative is the synthetic status returned by JavaThread.EffectiveStatus for the RUNNABLE
+//threads actually blocked in a native I/O call. It never appears in a dump.
+const StatusBlockedNative = "BLOCKED_NATIVE"
+
+var canonicalStatuses = []string{StatusNew, StatusRunnable, StatusBlocked, StatusWaiting, StatusTimedWaiting, StatusTerminated}
+
+//canonicalStatus maps the label of a Thread.State line to its canonical status. The label is the enum
+//constant, unless the line was localized: then the English constant is looked up in the rest of the
+//line, and finally in aliases. Unknown labels are returned unchanged.
+func canonicalStatus(label string, line string, aliases map[string]string) string {
+	for _, status := range canonicalStatuses {
+		if label == status {
+			return status
+		}
+	}
+	for _, token := range strings.FieldsFunc(line, func(r rune) bool { return r != '_' && (r < 'A' || r > 'Z') }) {
+		for _, status := range canonicalStatuses {
+			if token == status {
+				return status
+			}
+		}
+	}
+	if status, ok := aliases[label]; ok {
+		return status
+	}
+	return label
+}
+
+//statusDetail returns the text between the parentheses of a Thread.State line.
+func statusDetail(line string) string {
+	i, j := strings.IndexByte(line, '('), strings.LastIndexByte(line, ')')
+	if i < 0 || j < i {
+		return ""
+	}
+	return strings.TrimSpace(line[i+1 : j])
+}
 
 //JavaThreadDump represents all the information parsed for the complete stacktrace
 type JavaThreadDump struct {
-	Date          string                 `json:"date"`
-	VersionString string                 `json:"versionString"`
-	ByStack       map[string]int         `json:"byStack"`
-	ByStatus      map[string]int         `json:"byStatus"`
-	LockOwners    map[string]string      `json:"lockOwners"`
-	Threads       map[string]*JavaThread `json:"threads"`
-	TotalThreads  int                    `json:"totalThreads"`
-	Problems      []string               `json:"problems"`
-}
-
-func (jtd *JavaThreadDump) analyze() int {
-	jtd.Problems = make([]string, 0)
-	for tid, jt := range jtd.Threads {
-		if jt.Status == "BLOCKED" {
-			for _, lock := range jt.LocksWaiting {
-				if jtd.LockOwners[lock] != "" {
-					tname := jtd.Threads[jtd.LockOwners[lock]].Name
-					problem := fmt.Sprintf("%s[%s] blocked for %s[%s]. lock %s", jt.Name, tid, jtd.LockOwners[lock], tname, lock)
-					jtd.Problems = append(jtd.Problems, problem)
-				}
+	Date          string            `json:"date"`
+	Timestamp     time.Time         `json:"timestamp"`
+	VersionString string            `json:"versionString"`
+	ByStack       map[string]int    `json:"byStack"`
+	ByStatus      map[string]int    `json:"byStatus"`
+	LockOwners    map[string]string `json:"lockOwners"`
+	//ReleasedInWait maps the monitors a thread entered but released in Object.wait() to that thread.
+	//They are left out of LockOwners, which only holds the locks currently held.
+	ReleasedInWait map[string]string      `json:"releasedInWait,omitempty"`
+	Threads        map[string]*JavaThread `json:"threads"`
+	TotalThreads   int                    `json:"totalThreads"`
+	Problems       []string               `json:"problems"`
+	Analysis       AnalysisResult         `json:"analysis"`
+	ParseWarnings  []string               `json:"parseWarnings,omitempty"`
+	//StackGroups are the threads grouped by stack hash, sorted by descending count then by hash.
+	StackGroups []StackGroup `json:"stackGroups"`
+	//ThreadPools are the threads grouped by pool, keyed by the pool name: the thread names without
+	//their trailing counter, like "http-nio-8080-exec-" for "http-nio-8080-exec-42" or "pool-3-" for "pool-3-thread-17".
+	ThreadPools map[string]*PoolStats `json:"threadPools"`
+	//SystemThreads are the threads of the JVM itself, keyed by tid: the VM internal threads without a Java
+	//thread, like "VM Thread" or the GC threads, and the JIT compiler threads. They are left out of Threads,
+	//and so of TotalThreads and the other aggregates, which only cover the application threads.
+	SystemThreads map[string]*JavaThread `json:"systemThreads,omitempty"`
+	//JNIGlobalRefs is the number of JNI global references from the footer of the dump. A number growing
+	//from dump to dump hints at a native code leaking references.
+	JNIGlobalRefs int `json:"jniGlobalRefs,omitempty"`
+	//Format is the format of the parsed dump, "" when the dump wasn't parsed.
+	Format Format `json:"format,omitempty"`
+	//DeadlockReports are the deadlocks found by the JVM itself, printed after the threads.
+	DeadlockReports []DeadlockReport `json:"deadlockReports,omitempty"`
+	raw             string
+	thresholds      Thresholds
+	hashing         stackHashing
+	detectors       []func(*JavaThreadDump) []Problem
+	truncatedAt     int
+	threadsArray    bool
+}
+
+//Analyze recomputes the aggregates and the problems from the Threads, after they were modified.
+func (jtd *JavaThreadDump) Analyze() AnalysisResult {
+	jtd.TotalThreads = 0
+	jtd.ByStatus = make(map[string]int)
+	jtd.ByStack = make(map[string]int)
+	jtd.LockOwners = make(map[string]string)
+	jtd.ReleasedInWait = make(map[string]string)
+	for _, jt := range jtd.Threads {
+		jtd.addAggregates(jt)
+	}
+	for _, jt := range jtd.SystemThreads {
+		jt.hashing = jtd.hashing
+		jt.analyze()
+	}
+	return jtd.analyze()
+}
+
+//AddThreadAndReanalyze inserts jt, replacing the thread with the same TID if any, updates the
+//aggregates with it and reruns the problem detection. The locks of the threads removed from Threads
+//since the dump was analyzed are dropped from LockOwners and ReleasedInWait.
+func (jtd *JavaThreadDump) AddThreadAndReanalyze(jt *JavaThread) AnalysisResult {
+	// Each map may be missing on its own, ReleasedInWait is left out of the JSON when empty.
+	if jtd.Threads == nil {
+		jtd.Threads = make(map[string]*JavaThread)
+	}
+	if jtd.ByStatus == nil {
+		jtd.ByStatus = make(map[string]int)
+	}
+	if jtd.ByStack == nil {
+		jtd.ByStack = make(map[string]int)
+	}
+	if jtd.LockOwners == nil {
+		jtd.LockOwners = make(map[string]string)
+	}
+	if jtd.ReleasedInWait == nil {
+		jtd.ReleasedInWait = make(map[string]string)
+	}
+	for _, owners := range []map[string]string{jtd.LockOwners, jtd.ReleasedInWait} {
+		for lock, owner := range owners {
+			if jtd.Threads[owner] == nil {
+				delete(owners, lock)
+			}
+		}
+	}
+	if old := jtd.Threads[jt.TID]; old != nil {
+		jtd.removeAggregates(old)
+		jt.seq = old.seq
+	} else {
+		jt.seq = 1
+		for _, other := range jtd.Threads {
+			if other.seq >= jt.seq {
+				jt.seq = other.seq + 1
+			}
+		}
+	}
+	jtd.Threads[jt.TID] = jt
+	jtd.addAggregates(jt)
+	return jtd.analyze()
+}
+
+func (jtd *JavaThreadDump) addAggregates(jt *JavaThread) {
+	jt.hashing = jtd.hashing
+	jt.analyze()
+	jtd.TotalThreads += jt.count()
+	jtd.ByStack[jt.StackHash] += jt.count()
+	jtd.ByStatus[jt.Status] += jt.count()
+	for _, lock := range jt.LocksOwned {
+		if jt.releasedInWait(lock) {
+			jtd.ReleasedInWait[lock] = jt.TID
+		} else {
+			jtd.LockOwners[lock] = jt.TID
+		}
+	}
+	for _, lock := range jt.OwnableSynchronizers {
+		jtd.LockOwners[lock] = jt.TID
+	}
+}
+
+func (jtd *JavaThreadDump) removeAggregates(jt *JavaThread) {
+	jtd.TotalThreads -= jt.count()
+	if jtd.ByStack[jt.StackHash] -= jt.count(); jtd.ByStack[jt.StackHash] <= 0 {
+		delete(jtd.ByStack, jt.StackHash)
+	}
+	if jtd.ByStatus[jt.Status] -= jt.count(); jtd.ByStatus[jt.Status] <= 0 {
+		delete(jtd.ByStatus, jt.Status)
+	}
+	for _, locks := range [][]string{jt.LocksOwned, jt.OwnableSynchronizers} {
+		for _, lock := range locks {
+			if jtd.LockOwners[lock] == jt.TID {
+				delete(jtd.LockOwners, lock)
+			}
+		}
+	}
+	for _, lock := range jt.LocksOwned {
+		if jtd.ReleasedInWait[lock] == jt.TID {
+			delete(jtd.ReleasedInWait, lock)
+		}
+	}
+}
+
+//releasedInWait tells if the thread released lock in Object.wait(): the monitor of the synchronized
+//block is still printed as locked, but the thread is waiting on it or re-locking it.
+func (jt *JavaThread) releasedInWait(lock string) bool {
+	for _, locks := range [][]string{jt.WaitingOn, jt.LocksReacquiring} {
+		for _, l := range locks {
+			if l == lock {
+				return true
 			}
 		}
-		if jt.StackDepth > maxstackdepth && jt.Status != "RUNNABLE" {
-			problem := fmt.Sprintf("%s[%s] waiting with stack depth %d.", jt.Name, tid, jt.StackDepth)
-			jtd.Problems = append(jtd.Problems, problem)
+	}
+	return false
+}
+
+//OrphanLocks returns the sorted monitors some thread is waiting to lock but no thread in the dump owns,
+//even one that released it in Object.wait().
+func (jtd *JavaThreadDump) OrphanLocks() []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0)
+	for _, jt := range jtd.Threads {
+		for _, lock := range jt.LocksWaiting {
+			if jtd.LockOwners[lock] == "" && jtd.ReleasedInWait[lock] == "" && !seen[lock] {
+				seen[lock] = true
+				res = append(res, lock)
+			}
 		}
 	}
-	sort.Slice(jtd.Problems, func(i, j int) bool { return jtd.Problems[i] < jtd.Problems[j] })
-	return len(jtd.Problems)
+	sort.Strings(res)
+	return res
+}
+
+//Fingerprint returns a sha256 of the sorted (stack hash, status) pairs of the threads.
+//Dumps with the same thread composition share the fingerprint regardless of tids and addresses.
+func (jtd *JavaThreadDump) Fingerprint() string {
+	pairs := make([]string, 0, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		pairs = append(pairs, jt.StackHash+" "+jt.Status)
+	}
+	sort.Strings(pairs)
+	h := sha256.New()
+	for _, pair := range pairs {
+		h.Write([]byte(pair))
+		h.Write([]byte("\n"))
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+//BlockedRatio returns the fraction of the threads in BLOCKED state.
+func (jtd *JavaThreadDump) BlockedRatio() float64 {
+	return jtd.statusRatio(StatusBlocked)
+}
+
+//WaitingRatio returns the fraction of the threads in WAITING or TIMED_WAITING state.
+func (jtd *JavaThreadDump) WaitingRatio() float64 {
+	return jtd.statusRatio(StatusWaiting, StatusTimedWaiting)
+}
+
+//RunnableRatio returns the fraction of the threads in RUNNABLE state.
+func (jtd *JavaThreadDump) RunnableRatio() float64 {
+	return jtd.statusRatio(StatusRunnable)
+}
+
+func (jtd *JavaThreadDump) statusRatio(statuses ...string) float64 {
+	if jtd.TotalThreads == 0 {
+		return 0
+	}
+	count := 0
+	for _, status := range statuses {
+		count += jtd.ByStatus[status]
+	}
+	return float64(count) / float64(jtd.TotalThreads)
+}
+
+//Raw returns the original dump text. It is only available when parsed with Options.RetainRaw.
+func (jtd *JavaThreadDump) Raw() string {
+	return jtd.raw
+}
+
+//dumpJSON is a JavaThreadDump without its MarshalJSON method.
+type dumpJSON JavaThreadDump
+
+//MarshalJSON encodes the dump, with the threads as an array in dump order when parsed with
+//Options.ThreadsArray. The map of the threads is encoded sorted by tid otherwise.
+func (jtd *JavaThreadDump) MarshalJSON() ([]byte, error) {
+	if !jtd.threadsArray {
+		return json.Marshal((*dumpJSON)(jtd))
+	}
+	return json.Marshal(struct {
+		*dumpJSON
+		Threads []*JavaThread `json:"threads"`
+	}{(*dumpJSON)(jtd), jtd.OrderedThreads()})
 }
 
 //ToJSON get the json string of JavaThreadDump struct.
+//It is "" when the encoding fails, see WriteJSON to get the error.
 func (jtd *JavaThreadDump) ToJSON() string {
-	res2B, _ := json.Marshal(jtd)
 	var prettyJSON bytes.Buffer
-	json.Indent(&prettyJSON, res2B, "", "\t")
-	return prettyJSON.String()
+	jtd.WriteJSON(&prettyJSON)
+	return strings.TrimSuffix(prettyJSON.String(), "\n")
+}
+
+//WriteJSON writes the dump to w as indented JSON, like ToJSON followed by a newline, without building
+//the whole output in memory first.
+func (jtd *JavaThreadDump) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, jtd)
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(v)
 }
 
 //JavaThread represents the information parsed for a single thread
 type JavaThread struct {
 	Name           string   `json:"name"`
-	InternalNumber string   `json:"internalNumber"`
-	IsDaemon       bool     `json:"isDaemon"`
+	ThreadGroup    string   `json:"threadGroup,omitempty"`
+	InternalNumber string   `json:"internalNumber,omitempty"`
+	IsDaemon       bool     `json:"isDaemon,omitempty"`
 	Status         string   `json:"status"`
-	Prio           int      `json:"prio"`
-	OSPrio         int      `json:"osPrio"`
+	Prio           int      `json:"prio,omitempty"`
+	OSPrio         int      `json:"osPrio,omitempty"`
 	ThreadID       int64    `json:"threadId"`
 	TID            string   `json:"tid"`
 	NID            string   `json:"nid"`
-	Stack          []string `json:"stack"`
-	StackHash      string   `json:"stackHash"`
-	StackDepth     int      `json:"stackDepth"`
-	LocksOwned     []string `json:"locksOwned"`
-	LocksWaiting   []string `json:"locksWaiting"`
+	Stack          []string `json:"stack,omitempty"`
+	//StackHash covers the "at" frames, except the ones matching Options.IgnoreFramePatterns,
+	//only the top Options.StackHashFrames of them and without line numbers with Options.IgnoreLineNumbers.
+	StackHash string `json:"stackHash"`
+	//LogicalStackHash also covers the lock lines but not their addresses, so it also groups by lock classes.
+	LogicalStackHash string   `json:"logicalStackHash"`
+	StackDepth       int      `json:"stackDepth"`
+	LocksOwned       []string `json:"locksOwned,omitempty"`
+	LocksWaiting     []string `json:"locksWaiting,omitempty"`
+	//WaitingOn holds the monitors the thread is waiting on in Object.wait(), released until it is notified.
+	WaitingOn []string `json:"waitingOn,omitempty"`
+	//LocksReacquiring holds the monitors the thread released in Object.wait() and is trying to lock again.
+	LocksReacquiring []string `json:"locksReacquiring,omitempty"`
+	//ParkingOn holds the java.util.concurrent synchronizers the thread is parked on with LockSupport.park.
+	ParkingOn []string `json:"parkingOn,omitempty"`
+	//OwnableSynchronizers holds the synchronizers, like a ReentrantLock, listed as owned by jstack -l.
+	OwnableSynchronizers []string `json:"ownableSynchronizers,omitempty"`
+	//IsMain reports the application entry thread: named "main" or with a main method at the bottom of its stack.
+	IsMain bool `json:"isMain,omitempty"`
+	//IsCompiler reports a JIT compiler thread, like "C2 CompilerThread0".
+	IsCompiler bool `json:"isCompiler,omitempty"`
+	//Truncated reports a stack that doesn't reach the entry point of the thread: it ends with a "..." marker,
+	//or its bottom frame isn't a run or main method, like when the JVM cut it at MaxJavaStackTraceDepth.
+	Truncated bool `json:"truncated,omitempty"`
+	//CPUTimeMs is the CPU time consumed by the thread, from the "cpu=" field of the JDK 11+ headers.
+	CPUTimeMs float64 `json:"cpuTimeMs,omitempty"`
+	//ElapsedSec is the time since the thread started, from the "elapsed=" field of the JDK 11+ headers.
+	ElapsedSec float64 `json:"elapsedSec,omitempty"`
+	//Multiplicity is the number of threads a thread of a collapsed dump stands for, 0 for a parsed thread.
+	Multiplicity int `json:"multiplicity,omitempty"`
+	//MemberTIDs are the sorted tids of the threads a thread of a collapsed dump stands for.
+	MemberTIDs []string `json:"memberTids,omitempty"`
+	//LastSP is the last known Java stack pointer printed between brackets at the end of the header, like "0x00007f5c3a1fe000".
+	LastSP string `json:"lastSP,omitempty"`
+	//NativeID is the decoded NID, the OS thread id shown by "top -H" and in /proc/<pid>/task.
+	//ThreadID is the decoded TID, the address of the JVM thread structure, 0 when it isn't 0x-prefixed hex.
+	NativeID int64 `json:"nativeId"`
+	//StatusDetail is the detail between parentheses of the Thread.State line, like "sleeping" for
+	//"TIMED_WAITING (sleeping)" or "on object monitor", "" when there is none.
+	StatusDetail string `json:"statusDetail,omitempty"`
+	hashing      stackHashing
+	//seq is the 1-based position of the thread in the dump, 0 for a thread that wasn't parsed.
+	seq int
+	//vmInternal is set for a thread without a Java thread: neither a Thread.State line nor a frame follows its header.
+	vmInternal bool
+}
+
+//vmThreadStatuses maps the statuses of the VM internal thread headers to the canonical statuses.
+var vmThreadStatuses = map[string]string{"runnable": StatusRunnable, "waiting on condition": StatusWaiting}
+
+//isSystemThread tells if the thread belongs to JavaThreadDump.SystemThreads.
+func (jt *JavaThread) isSystemThread() bool {
+	return jt.vmInternal || isCompilerThread(jt.Name)
+}
+
+//count returns the number of threads jt stands for, its Multiplicity in a collapsed dump and 1 otherwise.
+func (jt *JavaThread) count() int {
+	if jt.Multiplicity > 0 {
+		return jt.Multiplicity
+	}
+	return 1
 }
 
 func (jt *JavaThread) analyze() {
-	h := sha256.New()
-	depth := 0
+	h := jt.hashing.newHash()
+	lh := jt.hashing.newHash()
+	depth, hashed := 0, 0
 	for _, stackLine := range jt.Stack {
+		full := jt.hashing.frames > 0 && hashed >= jt.hashing.frames
 		if strings.HasPrefix(stackLine, "\tat ") {
 			depth++
-			h.Write([]byte(stackLine))
+			if full || jt.hashing.ignored(stackLine[4:]) {
+				continue
+			}
+			hashed++
+			frame := jt.hashing.normalize(stackLine)
+			h.Write([]byte(frame))
+			lh.Write([]byte(frame))
+		} else if strings.HasPrefix(stackLine, "\t- ") && !full {
+			lh.Write([]byte(stripAddresses(stackLine)))
 		}
 	}
-	jt.StackHash = fmt.Sprintf("%x", h.Sum(nil))
+	jt.StackHash = hex.EncodeToString(h.Sum(nil))
+	jt.LogicalStackHash = hex.EncodeToString(lh.Sum(nil))
 	jt.StackDepth = depth
+	jt.IsMain = jt.Name == "main" || ParseFrame(jt.bottomFrame()).Method == "main"
+	jt.IsCompiler = isCompilerThread(jt.Name)
+	jt.Truncated = jt.truncated()
+	jt.LocksOwned = dedupeLocks(jt.LocksOwned)
+	jt.LocksWaiting = dedupeLocks(jt.LocksWaiting)
+}
+
+//dedupeLocks removes the repeated addresses of locks in place, keeping the first occurrence of each.
+//Recursive synchronized calls print a "- locked" line per frame for the same monitor.
+func dedupeLocks(locks []string) []string {
+	if len(locks) < 2 {
+		return locks
+	}
+	seen := make(map[string]bool, len(locks))
+	res := locks[:0]
+	for _, lock := range locks {
+		if !seen[lock] {
+			seen[lock] = true
+			res = append(res, lock)
+		}
+	}
+	return res
+}
+
+//stripAddresses removes the "0x..." addresses between angle brackets of a lock line,
+//"- locked <0x00000000c0a1b2d0> (a java.lang.Object)" becomes "- locked <> (a java.lang.Object)".
+//The "lock#N" pseudonyms of AnonymizeAddresses are removed too.
+func stripAddresses(line string) string {
+	var sb strings.Builder
+	for {
+		i := strings.Index(line, "<0x")
+		if j := strings.Index(line, "<lock#"); j >= 0 && (i < 0 || j < i) {
+			i = j
+		}
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(line[i:], '>')
+		if j < 0 {
+			break
+		}
+		sb.WriteString(line[:i+1])
+		line = line[i+j:]
+	}
+	sb.WriteString(line)
+	return sb.String()
 }
 
 //ToJSON get the json string of JavaThread struct.
+//It is "" when the encoding fails, see WriteJSON to get the error.
 func (jt *JavaThread) ToJSON() string {
 	jt.analyze()
-	res2B, _ := json.Marshal(jt)
 	var prettyJSON bytes.Buffer
-	json.Indent(&prettyJSON, res2B, "", "\t")
-	return prettyJSON.String()
+	jt.WriteJSON(&prettyJSON)
+	return strings.TrimSuffix(prettyJSON.String(), "\n")
+}
+
+//WriteJSON writes the thread to w as indented JSON, like ToJSON followed by a newline.
+func (jt *JavaThread) WriteJSON(w io.Writer) error {
+	return writeIndentedJSON(w, jt)
 }
 
 func newJavaThread() *JavaThread {
@@ -102,6 +486,10 @@ func newJavaThread() *JavaThread {
 	jt.Stack = make([]string, 0)
 	jt.LocksOwned = make([]string, 0)
 	jt.LocksWaiting = make([]string, 0)
+	jt.WaitingOn = make([]string, 0)
+	jt.LocksReacquiring = make([]string, 0)
+	jt.ParkingOn = make([]string, 0)
+	jt.OwnableSynchronizers = make([]string, 0)
 	return jt
 }
 
@@ -109,20 +497,240 @@ var re *regexp.Regexp
 var reWLock *regexp.Regexp
 var reStatus *regexp.Regexp
 var reLock *regexp.Regexp
+var reRLock *regexp.Regexp
+var reGroup *regexp.Regexp
+var reParking *regexp.Regexp
+var reWaitingOn *regexp.Regexp
+var reSynchronizer *regexp.Regexp
+var reHeaderNoNumber *regexp.Regexp
 var regexCompileOnce sync.Once
 
 //ParseJStack receives a jstack command output and parse it to extract the JavaThreadDump structure.
-func ParseJStack(jstackStr string) (*JavaThreadDump, error) {
-	lines := strings.Split(jstackStr, "\n")
+//The OpenJ9 javacores and jstack outputs are also understood and mapped onto the same structures, see JavaThreadDump.Format.
+//The parsing can be tuned with options, calling it without any gives the default behavior.
+func ParseJStack(jstackStr string, options ...Option) (*JavaThreadDump, error) {
+	return ParseJStackWithOptions(jstackStr, newOptions(options))
+}
+
+//ParseJStackWithOptions is like ParseJStack but allows to tune the parsing with opts.
+func ParseJStackWithOptions(jstackStr string, opts Options) (*JavaThreadDump, error) {
+	return parseJStack(strings.NewReader(jstackStr), opts, nil)
+}
+
+//ParseJStackReader is like ParseJStack but reads the jstack output from r line by line, so that
+//large dumps are never held in memory as a whole. Lines longer than 1MiB make it fail.
+func ParseJStackReader(r io.Reader, options ...Option) (*JavaThreadDump, error) {
+	return parseJStack(r, newOptions(options), nil)
+}
+
+//ParseStats describes the parsing of a jstack output.
+type ParseStats struct {
+	//LinesProcessed is the number of lines read, including the skipped ones.
+	LinesProcessed int
+	//ThreadsParsed is the number of thread headers that were parsed.
+	ThreadsParsed int
+	//LinesSkipped is the number of lines that were ignored: before the "Full thread dump" line,
+	//in the JVM footer, blank or unrecognized.
+	LinesSkipped int
+	//Duration is the time spent parsing and analyzing.
+	Duration time.Duration
+}
+
+//ParseJStackWithStats is like ParseJStack but also returns statistics about the parsing.
+//The statistics are filled even when the parsing fails.
+func ParseJStackWithStats(jstackStr string, options ...Option) (*JavaThreadDump, ParseStats, error) {
+	var stats ParseStats
+	jtd, err := parseJStack(strings.NewReader(jstackStr), newOptions(options), &stats)
+	return jtd, stats, err
+}
+
+var (
+	prefixFullThreadDump = []byte("Full thread dump ")
+	prefixThreadState    = []byte("java.lang.Thread.State:")
+	prefixJNIGlobalRefs  = []byte("JNI global references:")
+	prefixLocked         = []byte("\t- locked ")
+	prefixWaitingToLock  = []byte("\t- waiting to lock ")
+	prefixReLock         = []byte("\t- waiting to re-lock in wait() ")
+	prefixParking        = []byte("\t- parking to wait for ")
+	prefixWaitingOn      = []byte("\t- waiting on <")
+
+	prefixOwnableSynchronizers = []byte("   Locked ownable synchronizers:")
+	prefixSynchronizerItem     = []byte("\t- ")
+	prefixJNIGlobalRefsJDK11   = []byte("JNI global refs:")
+)
+
+//parseNID decodes a nid, which is usually 0x-prefixed hex but printed in decimal by some JVMs.
+func parseNID(nid string) (int64, error) {
+	if strings.HasPrefix(nid, "0x") || strings.HasPrefix(nid, "0X") {
+		return strconv.ParseInt(nid[2:], 16, 64)
+	}
+	return strconv.ParseInt(nid, 10, 64)
+}
+
+//parseJNIGlobalRefs returns the count of a "JNI global references: 1234" line, or of the
+//"JNI global refs: 17, weak refs: 0" one of the JDK 11+.
+func parseJNIGlobalRefs(b []byte) int {
+	s := bytes.TrimSpace(b[bytes.IndexByte(b, ':')+1:])
+	end := 0
+	for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+		end++
+	}
+	n, _ := strconv.Atoi(string(s[:end]))
+	return n
+}
+
+//dateLayout is the layout of the date line printed by jstack. It has no zone, so it is parsed as UTC.
+const dateLayout = "2006-01-02 15:04:05"
+
+func isDigits(b []byte) bool {
+	for _, c := range b {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return len(b) > 0
+}
+
+//noObjectReference is printed instead of the lock address when the JIT optimized the object away.
+//It doesn't identify a lock, so it is skipped instead of creating a phantom lock shared by all the threads.
+const noObjectReference = "no object reference available"
+
+//maxLineLength is the longest line accepted by the parser.
+const maxLineLength = 1024 * 1024
+
+func parseJStack(r io.Reader, opts Options, stats *ParseStats) (*JavaThreadDump, error) {
+	start := time.Now()
+	lines, threads, skipped := 0, 0, 0
+	if stats != nil {
+		defer func() {
+			*stats = ParseStats{LinesProcessed: lines, ThreadsParsed: threads, LinesSkipped: skipped, Duration: time.Since(start)}
+		}()
+	}
 	validVersion := false
+	logger := opts.logger()
 
+	jtd := new(JavaThreadDump)
+	jtd.ParseWarnings = make([]string, 0)
+	if opts.RetainRaw {
+		var raw strings.Builder
+		r = io.TeeReader(r, &raw)
+		defer func() { jtd.raw = raw.String() }()
+	}
+
+	// The threads are only sorted into Threads and SystemThreads once their stack was read.
+	parsed := make([]*JavaThread, 0)
+	addThread := func(jt *JavaThread) {
+		threads++
+		jt.seq = threads
+		parsed = append(parsed, jt)
+	}
+	p := newThreadParser(opts, logger, &jtd.ParseWarnings)
+	var deadlocks deadlockParser
+	var j9 *j9Parser
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	dateLine := 0
+	inFooter := false
+	for i := 0; scanner.Scan(); i++ {
+		if opts.MaxLines > 0 && i >= opts.MaxLines {
+			jtd.truncatedAt = opts.MaxLines
+			break
+		}
+		lines++
+		// Work on the scanner's buffer and only allocate a string for the lines that are kept.
+		// The attach protocol (jattach) can leave null bytes at the end of the output.
+		b := bytes.TrimRight(scanner.Bytes(), "\x00")
+		if j9 == nil && !validVersion && isOpenJ9Line(b) {
+			validVersion = true
+			jtd.Format = FormatOpenJ9
+			j9 = &j9Parser{jtd: jtd}
+		}
+		if j9 != nil {
+			jt, used := j9.parseLine(b)
+			if jt != nil {
+				addThread(jt)
+			}
+			if !used {
+				skipped++
+			}
+			continue
+		}
+		if i == 0 && isDigits(b) {
+			// Byte count or response code written before the dump by the attach protocol.
+			dateLine = 1
+		} else if i == dateLine && !bytes.HasPrefix(b, prefixFullThreadDump) {
+			jtd.Date = string(b)
+			if t, err := time.Parse(dateLayout, strings.TrimSpace(jtd.Date)); err == nil {
+				jtd.Timestamp = t
+			}
+		} else if bytes.HasPrefix(b, prefixFullThreadDump) {
+			validVersion = true
+			jtd.Format = FormatHotSpot
+			jtd.VersionString = string(b[len(prefixFullThreadDump):])
+		} else if bytes.HasPrefix(b, prefixJNIGlobalRefs) || bytes.HasPrefix(b, prefixJNIGlobalRefsJDK11) {
+			// End of the thread list, only the JVM footer follows.
+			inFooter = true
+			jtd.JNIGlobalRefs = parseJNIGlobalRefs(b)
+		} else if inFooter && deadlocks.parseLine(b) {
+			// Deadlock report of the JVM.
+		} else if !validVersion || inFooter || len(b) == 0 {
+			skipped++
+			continue
+		} else {
+			jt, used, err := p.parseLine(b, i+1)
+			if err != nil {
+				return jtd, err
+			}
+			if jt != nil {
+				addThread(jt)
+			}
+			if !used {
+				skipped++
+			}
+		}
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		return jtd, fmt.Errorf("line %d: longer than %d bytes", lines+1, maxLineLength)
+	} else if err != nil {
+		return jtd, fmt.Errorf("couldn't read the jstack output: %v", err)
+	}
+	if !validVersion {
+		return jtd, fmt.Errorf("couldn't find a valid java jstack output")
+	}
+	if j9 != nil {
+		j9.flush()
+	}
+	p.flush()
+	jtd.Threads = make(map[string]*JavaThread)
+	jtd.SystemThreads = make(map[string]*JavaThread)
+	for _, jt := range parsed {
+		if jt.isSystemThread() {
+			jtd.SystemThreads[jt.TID] = jt
+		} else {
+			jtd.Threads[jt.TID] = jt
+		}
+	}
+	jtd.DeadlockReports = deadlocks.reports
+	jtd.thresholds = opts.Thresholds
+	jtd.hashing = opts.stackHashing()
+	jtd.detectors = opts.Detectors
+	jtd.threadsArray = opts.ThreadsArray
+	jtd.Analyze()
+	logger.Debugf("Finished parsing.")
+	return jtd, nil
+}
+
+//compileRegexps compiles the regexps of the parser on the first call.
+func compileRegexps(logger Logger) {
 	regexCompileOnce.Do(func() {
 		var err error
-		re, err = regexp.Compile("\"([^\"]+)\" (#[0-9]+)( daemon)? prio=([0-9]+)? os_prio=([0-9]+) tid=([a-z0-9]+) nid=([a-z0-9]+) ([^$]*)")
+		// prio and os_prio are missing from the headers of some builds, like GraalVM, and the names may have escaped quotes.
+		// The JDK 19+ print the OS thread id between brackets after the number, "main" #1 [31348] prio=5.
+		re, err = regexp.Compile(`"(?P<name>(?:[^"\\]|\\.)+)"\s+(?P<number>#[0-9]+)(?:\s+\[[0-9]+\])?(?P<daemon>\s+daemon)?(?:\s*prio=(?P<prio>[0-9]+)?)?(?:\s+os_prio=(?P<osprio>[0-9]+))?(?:\s+cpu=(?P<cpu>[0-9.]+)ms)?(?:\s+elapsed=(?P<elapsed>[0-9.]+)s)?\s+tid=(?P<tid>[a-z0-9]+)\s+nid=(?P<nid>-?[a-zA-Z0-9]+)\s*(?P<status>[^$]*)`)
 		if err != nil {
 			re = nil
 		}
-		reStatus, err = regexp.Compile("[ ]+java.lang.Thread.State: ([^ ]*)")
+		reStatus, err = regexp.Compile("^java.lang.Thread.State:\\s*([^ ]*)")
 		if err != nil {
 			reStatus = nil
 		}
@@ -134,81 +742,32 @@ func ParseJStack(jstackStr string) (*JavaThreadDump, error) {
 		if err != nil {
 			reWLock = nil
 		}
-		log.Debugf("Parser regex loaded.")
-	})
-
-	jtd := new(JavaThreadDump)
-
-	currJT := newJavaThread()
-	jts := make(map[string]*JavaThread)
-	for i, line := range lines {
-		if i == 0 {
-			jtd.Date = line
-		} else if strings.HasPrefix(line, "Full thread dump ") {
-			validVersion = true
-			jtd.VersionString = line[17:]
-		} else if validVersion && strings.HasPrefix(line, "\"") {
-			if currJT.Name != "" {
-				currJT = newJavaThread()
-			}
-			res := re.FindStringSubmatch(line)
-			if len(res) > 0 {
-				currJT.Name = res[1]
-				currJT.InternalNumber = res[2]
-				currJT.IsDaemon = res[3] == " daemon"
-				prio, _ := strconv.Atoi(res[4])
-				currJT.Prio = prio
-				osprio, _ := strconv.Atoi(res[5])
-				currJT.OSPrio = osprio
-				currJT.TID = res[6]
-				currJT.NID = res[7]
-				threadID, _ := strconv.ParseInt(res[7][2:], 16, 64)
-				currJT.ThreadID = threadID
-				currJT.Status = res[8]
-				jts[currJT.TID] = currJT
-			}
-		} else if validVersion && strings.HasPrefix(line, "   java.lang.Thread.State:") {
-			res := reStatus.FindStringSubmatch(line)
-			if len(res) > 0 {
-				currJT.Status = res[1]
-			}
-		} else if validVersion && strings.HasPrefix(line, "\t") {
-			currJT.Stack = append(currJT.Stack, line)
-			if strings.HasPrefix(line, "\t- locked ") {
-				res := reLock.FindStringSubmatch(line)
-				if len(res) > 0 {
-					currJT.LocksOwned = append(currJT.LocksOwned, res[1])
-				} else {
-					log.Error("Failed to find lock ID. " + line)
-				}
-			} else if strings.HasPrefix(line, "\t- waiting to lock ") {
-				res := reWLock.FindStringSubmatch(line)
-				if len(res) > 0 {
-					currJT.LocksWaiting = append(currJT.LocksWaiting, res[1])
-				} else {
-					log.Error("Failed to find wait lock ID. " + line)
-				}
-			}
+		reParking, err = regexp.Compile("[\t]+- parking to wait for\\s+<([^>]+)>")
+		if err != nil {
+			reParking = nil
 		}
-	}
-	if !validVersion {
-		return jtd, fmt.Errorf("couldn't find a valid java jstack output")
-	}
-	jtd.Threads = jts
-	jtd.TotalThreads = len(jts)
-	jtd.ByStatus = make(map[string]int)
-	jtd.ByStack = make(map[string]int)
-	jtd.LockOwners = make(map[string]string)
-	jtd.Problems = make([]string, 0)
-	for _, jt := range jtd.Threads {
-		jt.analyze()
-		jtd.ByStack[jt.StackHash]++
-		jtd.ByStatus[jt.Status]++
-		for _, lock := range jt.LocksOwned {
-			jtd.LockOwners[lock] = jt.TID
+		reWaitingOn, err = regexp.Compile("[\t]+- waiting on <([^>]+)>")
+		if err != nil {
+			reWaitingOn = nil
 		}
-	}
-	jtd.analyze()
-	log.Debug("Finished parsing.")
-	return jtd, nil
+		reSynchronizer, err = regexp.Compile("^[\t]+- <([^>]+)>")
+		if err != nil {
+			reSynchronizer = nil
+		}
+		reGroup, err = regexp.Compile("\\s+group=(?:\"([^\"]*)\"|([^\\s\"]+))")
+		if err != nil {
+			reGroup = nil
+		}
+		reRLock, err = regexp.Compile("[\t]+- waiting to re-lock in wait\\(\\) <([^>]+)>")
+		if err != nil {
+			reRLock = nil
+		}
+		// The headers without Java thread number: the VM internal threads, like "VM Thread" or the GC threads,
+		// and the threads of the JDK 6 and 7.
+		reHeaderNoNumber, err = regexp.Compile(`"(?P<name>(?:[^"\\]|\\.)+)"(?P<daemon>\s+daemon)?(?:\s+prio=(?P<prio>[0-9]+))?(?:\s+os_prio=(?P<osprio>[0-9]+))?(?:\s+cpu=(?P<cpu>[0-9.]+)ms)?(?:\s+elapsed=(?P<elapsed>[0-9.]+)s)?\s+tid=(?P<tid>[a-z0-9]+)\s+nid=(?P<nid>-?[a-zA-Z0-9]+)\s*(?P<status>[^$]*)`)
+		if err != nil {
+			reHeaderNoNumber = nil
+		}
+		logger.Debugf("Parser regex loaded.")
+	})
 }
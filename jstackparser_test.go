@@ -0,0 +1,187 @@
+package jstackparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestToJSONDeterministic(t *testing.T) {
+	dump := readFixture(t, "jdk8.txt")
+	// The problems share their message and come out of the detector in the random order of the map.
+	detector := func(jtd *JavaThreadDump) []Problem {
+		problems := make([]Problem, 0)
+		for tid, jt := range jtd.Threads {
+			problems = append(problems, Problem{Category: "custom-" + jt.Status, Message: "custom check.", TIDs: []string{tid}})
+		}
+		return problems
+	}
+	var first string
+	for i := 0; i < 20; i++ {
+		jtd, err := ParseJStack(dump, WithDetectors(detector))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := jtd.ToJSON(); i == 0 {
+			first = got
+		} else if got != first {
+			t.Fatalf("parse %d gave a different ToJSON output", i+1)
+		}
+	}
+}
+
+func TestAddThreadAndReanalyzeAfterJSON(t *testing.T) {
+	// Decoded from a dump without monitors released in wait(), ReleasedInWait is nil.
+	var jtd JavaThreadDump
+	if err := json.Unmarshal([]byte(`{"byStatus":{"RUNNABLE":1},"byStack":{},"lockOwners":{},"threads":{}}`), &jtd); err != nil {
+		t.Fatal(err)
+	}
+	jt, err := ParseThread(`"pool-1-thread-1" #20 prio=5 os_prio=0 tid=0x00007f5c7c4b8000 nid=0x6ba0 in Object.wait() [0x00007f5c2a5f4000]
+   java.lang.Thread.State: WAITING (on object monitor)
+	at java.lang.Object.wait(Native Method)
+	- waiting on <0x00000000c0a1b300> (a java.util.LinkedList)
+	at java.lang.Object.wait(Object.java:502)
+	- locked <0x00000000c0a1b300> (a java.util.LinkedList)
+	at com.acme.Queue.take(Queue.java:10)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jtd.AddThreadAndReanalyze(jt)
+	if owner := jtd.ReleasedInWait["0x00000000c0a1b300"]; owner != jt.TID {
+		t.Errorf("ReleasedInWait owner = %q, want %q", owner, jt.TID)
+	}
+}
+
+func TestAddThreadAndReanalyzeAfterPrune(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "jdk8.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// http-nio-8080-exec-1 owns the lock http-nio-8080-exec-2 is blocked on.
+	delete(jtd.Threads, "0x00007f5c7c4b6000")
+	jt, err := ParseThread(`"worker" #40 prio=5 os_prio=0 tid=0x00007f5c7c4c0000 nid=0x6bb0 runnable [0x00007f5c2a3f2000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Worker.run(Worker.java:12)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ar := jtd.AddThreadAndReanalyze(jt)
+	if owner, ok := jtd.LockOwners["0x00000000c0a1b2d0"]; ok {
+		t.Errorf("lock of the pruned thread owned by %s, want it dropped", owner)
+	}
+	for _, p := range ar.Problems {
+		for _, tid := range p.TIDs {
+			if tid == "0x00007f5c7c4b6000" {
+				t.Errorf("problem %q about the pruned thread", p.Message)
+			}
+		}
+	}
+}
+
+//benchmarkThreads is the number of threads of the dump used by the benchmarks, the size of a busy application server.
+const benchmarkThreads = 5000
+
+//largeDump builds a JDK 11 jstack output with n threads, spread over pools in the usual states:
+//blocked on a monitor, waiting in wait(), parked on a synchronizer and reading a socket.
+func largeDump(n int) string {
+	var sb strings.Builder
+	sb.WriteString("2019-08-20 10:37:05\nFull thread dump OpenJDK 64-Bit Server VM (11.0.4+11 mixed mode):\n\n")
+	for i := 0; i < n; i++ {
+		tid := fmt.Sprintf("0x00007f5c7c%06x", i)
+		lock := fmt.Sprintf("0x00000000c0%06x", i%97)
+		switch i % 4 {
+		case 0:
+			fmt.Fprintf(&sb, "\"http-nio-8080-exec-%d\" #%d daemon prio=5 os_prio=0 cpu=%d.52ms elapsed=812.33s tid=%s nid=0x%x waiting for monitor entry  [0x00007f5c2a7f6000]\n", i, i+20, i%500, tid, 0x6b00+i)
+			sb.WriteString("   java.lang.Thread.State: BLOCKED (on object monitor)\n")
+			sb.WriteString("\tat com.acme.Service.doWork(Service.java:42)\n")
+			fmt.Fprintf(&sb, "\t- waiting to lock <%s> (a java.lang.Object)\n", lock)
+			sb.WriteString("\tat com.acme.web.Controller.handle(Controller.java:88)\n")
+		case 1:
+			fmt.Fprintf(&sb, "\"pool-%d-thread-%d\" #%d prio=5 os_prio=0 cpu=%d.10ms elapsed=812.30s tid=%s nid=0x%x in Object.wait()  [0x00007f5c2a5f4000]\n", i%7, i, i+20, i%300, tid, 0x6b00+i)
+			sb.WriteString("   java.lang.Thread.State: WAITING (on object monitor)\n")
+			sb.WriteString("\tat java.lang.Object.wait(java.base@11.0.4/Native Method)\n")
+			fmt.Fprintf(&sb, "\t- waiting on <%s> (a java.util.LinkedList)\n", lock)
+			sb.WriteString("\tat java.lang.Object.wait(java.base@11.0.4/Object.java:328)\n")
+			fmt.Fprintf(&sb, "\t- waiting to re-lock in wait() <%s> (a java.util.LinkedList)\n", lock)
+			sb.WriteString("\tat com.acme.Queue.take(Queue.java:10)\n")
+		case 2:
+			fmt.Fprintf(&sb, "\"ForkJoinPool.commonPool-worker-%d\" #%d daemon prio=5 os_prio=0 cpu=%d.01ms elapsed=810.02s tid=%s nid=0x%x waiting on condition  [0x00007f5c2a4f3000]\n", i, i+20, i%200, tid, 0x6b00+i)
+			sb.WriteString("   java.lang.Thread.State: WAITING (parking)\n")
+			sb.WriteString("\tat jdk.internal.misc.Unsafe.park(java.base@11.0.4/Native Method)\n")
+			fmt.Fprintf(&sb, "\t- parking to wait for  <%s> (a java.util.concurrent.ForkJoinPool)\n", lock)
+			sb.WriteString("\tat java.util.concurrent.locks.LockSupport.park(java.base@11.0.4/LockSupport.java:194)\n")
+			sb.WriteString("\tat java.util.concurrent.ForkJoinPool.runWorker(java.base@11.0.4/ForkJoinPool.java:1628)\n")
+			sb.WriteString("\tat java.util.concurrent.ForkJoinWorkerThread.run(java.base@11.0.4/ForkJoinWorkerThread.java:183)\n")
+		default:
+			fmt.Fprintf(&sb, "\"grpc-default-executor-%d\" #%d daemon prio=5 os_prio=0 cpu=%d.77ms elapsed=805.91s tid=%s nid=0x%x runnable  [0x00007f5c2a3f2000]\n", i, i+20, i%900, tid, 0x6b00+i)
+			sb.WriteString("   java.lang.Thread.State: RUNNABLE\n")
+			sb.WriteString("\tat java.net.SocketInputStream.socketRead0(java.base@11.0.4/Native Method)\n")
+			sb.WriteString("\tat java.net.SocketInputStream.read(java.base@11.0.4/SocketInputStream.java:168)\n")
+			fmt.Fprintf(&sb, "\t- locked <0x00000000d0%06x> (a java.io.BufferedInputStream)\n", i)
+			sb.WriteString("\tat com.acme.rpc.Client.call(Client.java:217)\n")
+		}
+		for d := 0; d < 20; d++ {
+			fmt.Fprintf(&sb, "\tat com.acme.framework.Layer%d.invoke(Layer%d.java:%d)\n", d, d, 30+d)
+		}
+		sb.WriteString("\tat java.lang.Thread.run(java.base@11.0.4/Thread.java:834)\n\n")
+	}
+	sb.WriteString("\"VM Thread\" os_prio=0 cpu=1021.18ms elapsed=812.40s tid=0x00007f5c7c0f3000 nid=0x6a85 runnable  \n\n")
+	sb.WriteString("JNI global refs: 1234, weak refs: 0\n\n")
+	return sb.String()
+}
+
+func BenchmarkParseJStack(b *testing.B) {
+	dump := largeDump(benchmarkThreads)
+	b.SetBytes(int64(len(dump)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseJStack(dump); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseJStackReader(b *testing.B) {
+	dump := largeDump(benchmarkThreads)
+	b.SetBytes(int64(len(dump)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseJStackReader(strings.NewReader(dump)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//BenchmarkAnalyze measures the aggregation after the parsing, where the stack hashes are computed.
+func BenchmarkAnalyze(b *testing.B) {
+	dump := largeDump(benchmarkThreads)
+	for _, bm := range []struct {
+		name string
+		algo HashAlgo
+	}{{"SHA256", HashSHA256}, {"FNV", HashFNV}} {
+		b.Run(bm.name, func(b *testing.B) {
+			jtd, err := ParseJStack(dump, WithHashAlgo(bm.algo))
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				jtd.Analyze()
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//lockOrder is an observed acquisition of lock After while holding lock Before.
+type lockOrder struct {
+	Before string
+	After  string
+}
+
+//acquisitionOrder returns the locks of the thread in the order they were acquired.
+//jstack prints the innermost frame first, so the owned locks are listed from the newest to the oldest,
+//and the locks the thread is waiting for come after all of them.
+func (jt *JavaThread) acquisitionOrder() []string {
+	locks := make([]string, 0, len(jt.LocksOwned)+len(jt.LocksWaiting))
+	for i := len(jt.LocksOwned) - 1; i >= 0; i-- {
+		locks = append(locks, jt.LocksOwned[i])
+	}
+	return append(locks, jt.LocksWaiting...)
+}
+
+//LockOrderWarnings reports pairs of locks acquired in opposite orders by different threads.
+//Such threads are not necessarily deadlocked yet, but will be if they interleave badly.
+func (jtd *JavaThreadDump) LockOrderWarnings() []string {
+	seenBy := make(map[lockOrder]string)
+	tids := make([]string, 0, len(jtd.Threads))
+	for tid := range jtd.Threads {
+		tids = append(tids, tid)
+	}
+	sort.Strings(tids)
+	for _, tid := range tids {
+		locks := jtd.Threads[tid].acquisitionOrder()
+		for i := range locks {
+			for j := i + 1; j < len(locks); j++ {
+				order := lockOrder{Before: locks[i], After: locks[j]}
+				if order.Before != order.After && seenBy[order] == "" {
+					seenBy[order] = tid
+				}
+			}
+		}
+	}
+	warnings := make([]string, 0)
+	for order, tid := range seenBy {
+		otherTID := seenBy[lockOrder{Before: order.After, After: order.Before}]
+		if otherTID == "" || otherTID == tid || order.Before > order.After {
+			continue
+		}
+		jt, other := jtd.Threads[tid], jtd.Threads[otherTID]
+		warning := fmt.Sprintf("%s[%s] acquires %s before %s but %s[%s] acquires them in the opposite order.", jt.Name, tid, order.Before, order.After, other.Name, otherTID)
+		warnings = append(warnings, warning)
+	}
+	sort.Strings(warnings)
+	return warnings
+}
+
+//NormalizeAddress returns addr in lowercase without the leading zeros, "0x00000000C0A1B2C8" becomes
+//"0xc0a1b2c8", so that the addresses printed with different widths can be compared.
+func NormalizeAddress(addr string) string {
+	if !strings.HasPrefix(addr, "0x") && !strings.HasPrefix(addr, "0X") {
+		return addr
+	}
+	digits := strings.TrimLeft(strings.ToLower(addr[2:]), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	return "0x" + digits
+}
+
+//LockAddressWidth returns the most common number of hex digits of the lock addresses, 0 when the
+//dump has no lock. It is 16 on 64-bit JVMs and 8 on 32-bit ones: dumps with different widths come
+//from differently configured JVMs and can't be correlated by address.
+func (jtd *JavaThreadDump) LockAddressWidth() int {
+	widths := make(map[int]int)
+	for _, jt := range jtd.Threads {
+		for _, locks := range [][]string{jt.LocksOwned, jt.LocksWaiting, jt.WaitingOn, jt.LocksReacquiring, jt.ParkingOn, jt.OwnableSynchronizers} {
+			for _, lock := range locks {
+				if strings.HasPrefix(lock, "0x") {
+					widths[len(lock)-2]++
+				}
+			}
+		}
+	}
+	width, max := 0, 0
+	for w, count := range widths {
+		if count > max || (count == max && w > width) {
+			width, max = w, count
+		}
+	}
+	return width
+}
+
+//UncontendedLocks returns the sorted locks owned by some thread that no thread is waiting for,
+//whether waiting to lock, to re-lock after wait() or parked.
+func (jtd *JavaThreadDump) UncontendedLocks() []string {
+	contended := make(map[string]bool)
+	for _, jt := range jtd.Threads {
+		for _, locks := range [][]string{jt.LocksWaiting, jt.LocksReacquiring, jt.ParkingOn} {
+			for _, lock := range locks {
+				contended[lock] = true
+			}
+		}
+	}
+	res := make([]string, 0)
+	for lock := range jtd.LockOwners {
+		if !contended[lock] {
+			res = append(res, lock)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+//ConditionWaiters maps the objects threads are waiting on in Object.wait(), from their "- waiting on" lines,
+//to the sorted tids of these threads. Many threads waiting on the same object usually share a condition,
+//like an empty work queue: an idle pool, or a stuck producer.
+func (jtd *JavaThreadDump) ConditionWaiters() map[string][]string {
+	waiters := make(map[string][]string)
+	for tid, jt := range jtd.Threads {
+		for _, lock := range jt.WaitingOn {
+			waiters[lock] = append(waiters[lock], tid)
+		}
+	}
+	for _, tids := range waiters {
+		sort.Strings(tids)
+	}
+	return waiters
+}
+
+//LockImpactReport describes the threads stalled by a lock.
+type LockImpactReport struct {
+	Lock string `json:"lock"`
+	//Owner is the tid of the thread holding the lock, "" when no thread of the dump holds it.
+	Owner string `json:"owner"`
+	//DirectWaiters are the sorted tids of the threads waiting to lock, to re-lock or parked on the lock.
+	DirectWaiters []string `json:"directWaiters"`
+	//Stalled are the sorted tids of the direct waiters and of the threads transitively waiting for them.
+	Stalled []string `json:"stalled"`
+}
+
+//waitedLocks returns the locks the thread is waiting to lock, to re-lock after wait() or parked on.
+func (jt *JavaThread) waitedLocks() []string {
+	locks := make([]string, 0, len(jt.LocksWaiting)+len(jt.LocksReacquiring)+len(jt.ParkingOn))
+	locks = append(locks, jt.LocksWaiting...)
+	locks = append(locks, jt.LocksReacquiring...)
+	return append(locks, jt.ParkingOn...)
+}
+
+//LockImpact reports the owner of the lock at lockAddr, the threads waiting for it and all the threads
+//stalled behind it: waiting for a lock held by one of its waiters, and so on. The address is compared
+//after NormalizeAddress, so its width and case don't matter.
+func (jtd *JavaThreadDump) LockImpact(lockAddr string) LockImpactReport {
+	lockAddr = NormalizeAddress(lockAddr)
+	report := LockImpactReport{Lock: lockAddr, DirectWaiters: make([]string, 0), Stalled: make([]string, 0)}
+	for lock, owner := range jtd.LockOwners {
+		if NormalizeAddress(lock) == lockAddr {
+			report.Lock, report.Owner = lock, owner
+		}
+	}
+	g := jtd.LockGraph()
+	direct := make(map[string]bool)
+	for _, e := range g.Edges {
+		if e.Kind == EdgeWaitsFor && NormalizeAddress(e.To) == lockAddr && !direct[e.From] {
+			report.Lock = e.To
+			direct[e.From] = true
+			report.DirectWaiters = append(report.DirectWaiters, e.From)
+		}
+	}
+	sort.Strings(report.DirectWaiters)
+	report.Stalled = g.stalled(report.DirectWaiters, report.Owner)
+	return report
+}
+
+//DeadlockHop is a thread of a deadlock cycle with the lock it is stuck on, held by the next thread of the cycle.
+type DeadlockHop struct {
+	TID  string `json:"tid"`
+	Lock string `json:"lock"`
+}
+
+//DetectDeadlocks returns the cycles of threads waiting for locks held by each other, of any length.
+//Each cycle lists the threads in wait order starting with the smallest tid, the cycles are sorted.
+//Locks without a visible owner and threads re-locking their own monitor don't make a cycle.
+func (jtd *JavaThreadDump) DetectDeadlocks() [][]DeadlockHop {
+	return jtd.deadlocks(jtd.LockGraph())
+}
+
+func (jtd *JavaThreadDump) deadlocks(g *Graph) [][]DeadlockHop {
+	cycles := g.Cycles()
+	deadlocks := make([][]DeadlockHop, len(cycles))
+	for i, cycle := range cycles {
+		deadlocks[i] = make([]DeadlockHop, len(cycle))
+		for j, tid := range cycle {
+			deadlocks[i][j] = DeadlockHop{TID: tid, Lock: jtd.lockHeldBy(tid, cycle[(j+1)%len(cycle)])}
+		}
+	}
+	return deadlocks
+}
+
+//lockHeldBy returns the first lock tid waits for that owner holds.
+func (jtd *JavaThreadDump) lockHeldBy(tid, owner string) string {
+	for _, lock := range jtd.Threads[tid].waitedLocks() {
+		if jtd.LockOwners[lock] == owner {
+			return lock
+		}
+	}
+	return ""
+}
+
+//LockStat is a lock with the number of threads waiting for it, see LockContention.
+type LockStat struct {
+	Lock string `json:"lock"`
+	//Owner is the tid of the thread holding the lock, "" when no thread of the dump holds it.
+	Owner       string `json:"owner"`
+	OwnerName   string `json:"ownerName"`
+	OwnerStatus string `json:"ownerStatus"`
+	//Waiters is the number of threads waiting to lock, to re-lock or parked on the lock.
+	Waiters int `json:"waiters"`
+}
+
+//LockContention returns the locks that threads are waiting for, the most contended first, then by address.
+func (jtd *JavaThreadDump) LockContention() []LockStat {
+	waiters := make(map[string]int)
+	for _, jt := range jtd.Threads {
+		for _, lock := range jt.waitedLocks() {
+			waiters[lock] += jt.count()
+		}
+	}
+	stats := make([]LockStat, 0, len(waiters))
+	for lock, count := range waiters {
+		stat := LockStat{Lock: lock, Owner: jtd.LockOwners[lock], Waiters: count}
+		if owner := jtd.Threads[stat.Owner]; owner != nil {
+			stat.OwnerName, stat.OwnerStatus = owner.Name, owner.Status
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Waiters != stats[j].Waiters {
+			return stats[i].Waiters > stats[j].Waiters
+		}
+		return stats[i].Lock < stats[j].Lock
+	})
+	return stats
+}
+
+//Bottleneck is a thread stuck itself while holding locks other threads wait for, see DetectBottlenecks.
+type Bottleneck struct {
+	TID    string `json:"tid"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	//Locks are the sorted locks the thread holds that other threads wait for.
+	Locks []string `json:"locks"`
+	//Waiters is the number of threads waiting behind the thread, directly or through the locks of other waiters.
+	Waiters int `json:"waiters"`
+	//WaiterTIDs are the sorted tids of these threads.
+	WaiterTIDs []string `json:"waiterTids"`
+}
+
+//DetectBottlenecks returns the threads BLOCKED, WAITING or TIMED_WAITING while holding locks other threads
+//wait for, with all the threads stalled behind them: waiting for one of their locks, or for a lock held by
+//such a waiter, and so on. Unlike a deadlock it needs no cycle, one stuck thread can hold up many others.
+//The bottlenecks are sorted by descending number of waiters, then by tid.
+func (jtd *JavaThreadDump) DetectBottlenecks() []Bottleneck {
+	return jtd.bottlenecks(jtd.LockGraph())
+}
+
+//bottlenecks is DetectBottlenecks on g, the lock graph of the dump.
+func (jtd *JavaThreadDump) bottlenecks(g *Graph) []Bottleneck {
+	// waited holds the locks some thread other than their owner waits for.
+	waited := make(map[string]bool)
+	for _, e := range g.Edges {
+		if e.Kind == EdgeWaitsFor && jtd.LockOwners[e.To] != e.From {
+			waited[e.To] = true
+		}
+	}
+	bottlenecks := make([]Bottleneck, 0)
+	for _, node := range g.Nodes {
+		jt := node.Thread
+		if jt == nil || len(g.waitedBy[node.ID]) == 0 || (jt.Status != StatusBlocked && jt.Status != StatusWaiting && jt.Status != StatusTimedWaiting) {
+			continue
+		}
+		b := Bottleneck{TID: node.ID, Name: jt.Name, Status: jt.Status, Locks: make([]string, 0), WaiterTIDs: g.Behind(node.ID)}
+		for _, e := range g.Edges {
+			if e.Kind == EdgeOwns && e.From == node.ID && waited[e.To] {
+				b.Locks = append(b.Locks, e.To)
+			}
+		}
+		for _, waiter := range b.WaiterTIDs {
+			b.Waiters += jtd.Threads[waiter].count()
+		}
+		bottlenecks = append(bottlenecks, b)
+	}
+	sort.Slice(bottlenecks, func(i, j int) bool {
+		if bottlenecks[i].Waiters != bottlenecks[j].Waiters {
+			return bottlenecks[i].Waiters > bottlenecks[j].Waiters
+		}
+		return bottlenecks[i].TID < bottlenecks[j].TID
+	})
+	return bottlenecks
+}
@@ -0,0 +1,108 @@
+package jstackparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+//chainDump has a wait chain: a holds 0x01 and waits for the orphan lock 0x00, b and d wait for 0x01,
+//b holds 0x02 that c waits for.
+const chainDump = `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"a" #11 prio=5 os_prio=0 tid=0x0a nid=0x10a waiting for monitor entry [0x00007f5c2a7f6000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.A.run(A.java:1)
+	- waiting to lock <0x00> (a java.lang.Object)
+	- locked <0x01> (a java.lang.Object)
+
+"b" #12 prio=5 os_prio=0 tid=0x0b nid=0x10b waiting for monitor entry [0x00007f5c2a6f5000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.B.run(B.java:1)
+	- waiting to lock <0x01> (a java.lang.Object)
+	- locked <0x02> (a java.lang.Object)
+
+"c" #13 prio=5 os_prio=0 tid=0x0c nid=0x10c waiting for monitor entry [0x00007f5c2a5f4000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.C.run(C.java:1)
+	- waiting to lock <0x02> (a java.lang.Object)
+
+"d" #14 prio=5 os_prio=0 tid=0x0d nid=0x10d waiting on condition [0x00007f5c2a4f3000]
+   java.lang.Thread.State: WAITING (parking)
+	at sun.misc.Unsafe.park(Native Method)
+	- parking to wait for  <0x01> (a java.util.concurrent.locks.ReentrantLock$NonfairSync)
+	at com.acme.D.run(D.java:1)
+
+JNI global references: 12
+`
+
+func TestLockImpact(t *testing.T) {
+	jtd, err := ParseJStack(chainDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LockImpactReport{Lock: "0x01", Owner: "0x0a", DirectWaiters: []string{"0x0b", "0x0d"}, Stalled: []string{"0x0b", "0x0c", "0x0d"}}
+	if got := jtd.LockImpact("0x0000000000000001"); !reflect.DeepEqual(got, want) {
+		t.Errorf("LockImpact() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectBottlenecks(t *testing.T) {
+	jtd, err := ParseJStack(chainDump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Bottleneck{
+		{TID: "0x0a", Name: "a", Status: StatusBlocked, Locks: []string{"0x01"}, Waiters: 3, WaiterTIDs: []string{"0x0b", "0x0c", "0x0d"}},
+		{TID: "0x0b", Name: "b", Status: StatusBlocked, Locks: []string{"0x02"}, Waiters: 1, WaiterTIDs: []string{"0x0c"}},
+	}
+	if got := jtd.DetectBottlenecks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectBottlenecks() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDetectDeadlocks(t *testing.T) {
+	// A three threads cycle, mixing monitors and a synchronizer, plus a thread re-locking its own monitor.
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"c" #13 prio=5 os_prio=0 tid=0x0c nid=0x10c waiting on condition [0x00007f5c2a5f4000]
+   java.lang.Thread.State: WAITING (parking)
+	at sun.misc.Unsafe.park(Native Method)
+	- parking to wait for  <0x01> (a java.util.concurrent.locks.ReentrantLock$NonfairSync)
+	at com.acme.C.run(C.java:1)
+	- locked <0x03> (a java.lang.Object)
+
+"b" #12 prio=5 os_prio=0 tid=0x0b nid=0x10b waiting for monitor entry [0x00007f5c2a6f5000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.B.run(B.java:1)
+	- waiting to lock <0x03> (a java.lang.Object)
+	- locked <0x02> (a java.lang.Object)
+
+"a" #11 prio=5 os_prio=0 tid=0x0a nid=0x10a waiting for monitor entry [0x00007f5c2a7f6000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.A.run(A.java:1)
+	- waiting to lock <0x02> (a java.lang.Object)
+	- locked <0x01> (a java.lang.Object)
+
+"e" #15 prio=5 os_prio=0 tid=0x0e nid=0x10e waiting for monitor entry [0x00007f5c2a4f3000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at java.lang.Object.wait(Native Method)
+	at com.acme.E.run(E.java:1)
+	- waiting to re-lock in wait() <0x04> (a java.lang.Object)
+	- locked <0x04> (a java.lang.Object)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]DeadlockHop{{{"0x0a", "0x02"}, {"0x0b", "0x03"}, {"0x0c", "0x01"}}}
+	if got := jtd.DetectDeadlocks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectDeadlocks() = %+v, want %+v", got, want)
+	}
+	if problems := jtd.Analysis.ByCategory(CategoryDeadlock); len(problems) != 1 || !reflect.DeepEqual(problems[0].TIDs, []string{"0x0a", "0x0b", "0x0c"}) {
+		t.Errorf("deadlock problems = %v, want the cycle of a, b and c", problems)
+	}
+}
@@ -0,0 +1,292 @@
+package jstackparser
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Format is the format of a thread dump, which tells the JVM that printed it.
+type Format string
+
+const (
+	//FormatHotSpot is the jstack output of the HotSpot JVMs, like OpenJDK and Oracle.
+	FormatHotSpot Format = "HotSpot"
+	//FormatOpenJ9 is the javacore of the IBM J9 and Eclipse OpenJ9 JVMs, or its thread section alone, or the
+	//output of their jstack tool. That one prints the threads like java.lang.management.ThreadInfo, without
+	//tid nor nid: the threads are keyed by their Java thread number, like "#1", and their locks are identity hashes.
+	FormatOpenJ9 Format = "OpenJ9"
+)
+
+var (
+	prefixJ9Section = []byte("0SECTION")
+	infixJ9VMThread = []byte(`" J9VMThread:`)
+	reJ9Header      = regexp.MustCompile(`^"(.*)" J9VMThread:(0x[0-9a-fA-F]+)(.*)$`)
+	reJ9State       = regexp.MustCompile(`state:([A-Z]+)`)
+	reJ9Prio        = regexp.MustCompile(`prio=([0-9]+)`)
+	reJ9JavaThread  = regexp.MustCompile(`getId:(0x[0-9a-fA-F]+|[0-9]+)(?:, isDaemon:(true|false))?`)
+	reJ9NativeID    = regexp.MustCompile(`native thread ID:(0x[0-9a-fA-F]+|[0-9]+)`)
+	reJ9Lock        = regexp.MustCompile(`([^\s:]+)@(0x[0-9a-fA-F]+)`)
+	// The threads of the OpenJ9 jstack, "main" prio=5 Id=1 WAITING on java.lang.Object@1b6d3586 owned by "t" Id=2.
+	reJ9JstackHeader = regexp.MustCompile(`^"(.*)"( daemon)?(?: prio=([0-9]+))? Id=([0-9]+) ([A-Z_]+)`)
+	reJ9JstackLock   = regexp.MustCompile(`^-\s+(locked|waiting on|blocked on|parking to wait for)\s+(\S+)@([0-9a-fA-F]+)$`)
+	reJ9JstackSync   = regexp.MustCompile(`^-\s+(\S+)@([0-9a-fA-F]+)$`)
+)
+
+//j9Statuses maps the state codes of the OpenJ9 thread headers to the canonical statuses.
+//The codes don't tell a timed wait from an untimed one, so both are StatusWaiting.
+var j9Statuses = map[string]string{
+	"R":  StatusRunnable,
+	"CW": StatusWaiting,
+	"P":  StatusWaiting,
+	"B":  StatusBlocked,
+	"Z":  StatusTerminated,
+}
+
+//isOpenJ9Line tells if b shows that the dump is in the OpenJ9 format: the first line of a javacore,
+//or a thread header of a javacore or of the OpenJ9 jstack.
+func isOpenJ9Line(b []byte) bool {
+	return bytes.HasPrefix(b, prefixJ9Section) || (len(b) > 0 && b[0] == '"' && (bytes.Contains(b, infixJ9VMThread) || reJ9JstackHeader.Match(b)))
+}
+
+//j9Parser parses an OpenJ9 dump into the structures of the HotSpot ones: the frames and lock lines are
+//rewritten like jstack prints them, so that the stack hashes and the analysis work the same.
+//The javacore lines start with a tag like "3XMTHREADINFO", the thread section alone may not have them.
+type j9Parser struct {
+	jtd     *JavaThreadDump
+	current *JavaThread
+	// pendingLock is the lock line of the 3XMTHREADBLOCK line, printed before the stack, that goes after the top frame.
+	pendingLock string
+	// inCurrentThread is set in the "Current thread" section, which repeats a thread of the thread list.
+	inCurrentThread bool
+	// jstack is set for a thread of the OpenJ9 jstack, whose frames and lock lines are already like the HotSpot ones.
+	jstack bool
+	// inSynchronizers is set in the "Number of locked synchronizers" section of a thread of the OpenJ9 jstack.
+	inSynchronizers bool
+}
+
+//parseLine parses a line of the dump. It returns the thread when the line is a header, and whether the line was used.
+func (p *j9Parser) parseLine(b []byte) (*JavaThread, bool) {
+	tag, line := splitJ9Tag(string(b))
+	switch {
+	case tag == "1XMCURTHDINFO":
+		p.inCurrentThread = true
+	case tag == "1XMTHDINFO":
+		p.inCurrentThread = false
+	case p.inCurrentThread:
+		return nil, false
+	case tag == "1TIDATETIME":
+		p.parseDate(line)
+	case tag == "1CIJAVAVERSION":
+		p.jtd.VersionString = line
+	case strings.HasPrefix(line, `"`):
+		jt := p.parseHeader(line)
+		return jt, jt != nil
+	case tag == "3XMTHREADINFO":
+		// An "Anonymous native thread" has no Java thread, its lines must not go to the previous thread.
+		p.flush()
+		p.current = nil
+		return nil, false
+	case p.current == nil:
+		return nil, false
+	case p.jstack:
+		return nil, p.parseJstackLine(line)
+	case strings.HasPrefix(line, "at "):
+		p.current.Stack = append(p.current.Stack, "\tat "+j9Frame(line[3:]))
+		p.flushLock()
+	case strings.HasPrefix(line, "(java/lang/Thread getId:"):
+		if res := reJ9JavaThread.FindStringSubmatch(line); len(res) > 0 {
+			if id, err := parseNID(res[1]); err == nil {
+				p.current.InternalNumber = "#" + strconv.FormatInt(id, 10)
+			}
+			p.current.IsDaemon = res[2] == "true"
+		}
+	case strings.HasPrefix(line, "(native thread ID:"):
+		if res := reJ9NativeID.FindStringSubmatch(line); len(res) > 0 {
+			p.current.NID = res[1]
+			p.current.NativeID, _ = parseNID(res[1])
+		}
+	case strings.HasPrefix(line, "(entered lock:"):
+		if class, addr := j9Lock(line); addr != "" {
+			p.current.LocksOwned = append(p.current.LocksOwned, addr)
+			p.current.Stack = append(p.current.Stack, "\t- locked <"+addr+"> (a "+class+")")
+		}
+	case strings.HasPrefix(line, "Blocked on:"):
+		p.addPendingLock(&p.current.LocksWaiting, line, "\t- waiting to lock <%s> (a %s)")
+	case strings.HasPrefix(line, "Waiting on:"):
+		p.addPendingLock(&p.current.WaitingOn, line, "\t- waiting on <%s> (a %s)")
+	case strings.HasPrefix(line, "Parked on:"):
+		p.addPendingLock(&p.current.ParkingOn, line, "\t- parking to wait for  <%s> (a %s)")
+	default:
+		return nil, false
+	}
+	return nil, true
+}
+
+func (p *j9Parser) parseDate(line string) {
+	p.jtd.Date = strings.TrimSpace(strings.TrimPrefix(line, "Date:"))
+	// "2019/08/20 at 10:37:05:123", the milliseconds follow a colon that time.Parse doesn't accept.
+	const layout = "2006/01/02 at 15:04:05"
+	if len(p.jtd.Date) >= len(layout) {
+		if t, err := time.Parse(layout, p.jtd.Date[:len(layout)]); err == nil {
+			p.jtd.Timestamp = t
+		}
+	}
+}
+
+//parseHeader parses a header like
+//`"main" J9VMThread:0x00000000021E3D00, omrthread_t:0x00007F2B3C00B1F8, java/lang/Thread:0x00000000E0018B10, state:CW, prio=5`.
+func (p *j9Parser) parseHeader(line string) *JavaThread {
+	p.flush()
+	p.jstack, p.inSynchronizers = false, false
+	res := reJ9Header.FindStringSubmatch(line)
+	if len(res) == 0 {
+		p.current = p.parseJstackHeader(line)
+		return p.current
+	}
+	jt := newJavaThread()
+	jt.Name, jt.TID = res[1], res[2]
+	jt.ThreadID = parseTID(jt.TID)
+	if state := reJ9State.FindStringSubmatch(res[3]); len(state) > 0 {
+		jt.Status = state[1]
+		if status, ok := j9Statuses[state[1]]; ok {
+			jt.Status = status
+		}
+		if state[1] == "P" {
+			jt.StatusDetail = "parking"
+		}
+	}
+	if prio := reJ9Prio.FindStringSubmatch(res[3]); len(prio) > 0 {
+		jt.Prio, _ = strconv.Atoi(prio[1])
+	}
+	p.current = jt
+	return jt
+}
+
+//parseJstackHeader parses a header of the OpenJ9 jstack like `"main" prio=5 Id=1 WAITING on java.lang.Object@1b6d3586`,
+//it returns nil when line isn't one.
+func (p *j9Parser) parseJstackHeader(line string) *JavaThread {
+	res := reJ9JstackHeader.FindStringSubmatch(line)
+	if len(res) == 0 {
+		return nil
+	}
+	p.jstack = true
+	jt := newJavaThread()
+	jt.Name = res[1]
+	jt.IsDaemon = res[2] != ""
+	jt.Prio, _ = strconv.Atoi(res[3])
+	jt.InternalNumber = "#" + res[4]
+	jt.TID = jt.InternalNumber
+	jt.Status = res[5]
+	return jt
+}
+
+//parseJstackLine parses a line of the stack of a thread of the OpenJ9 jstack, and returns whether it was used.
+//The lock lines name the objects with their identity hash, like "- locked java.lang.Object@7a81197d".
+func (p *j9Parser) parseJstackLine(line string) bool {
+	jt := p.current
+	if strings.HasPrefix(line, "at ") || line == "..." {
+		jt.Stack = append(jt.Stack, "\t"+line)
+		return true
+	}
+	if strings.HasPrefix(line, "Number of locked synchronizers") {
+		p.inSynchronizers = true
+		return true
+	}
+	if res := reJ9JstackSync.FindStringSubmatch(line); len(res) > 0 && p.inSynchronizers {
+		jt.OwnableSynchronizers = append(jt.OwnableSynchronizers, "0x"+res[2])
+		return true
+	}
+	res := reJ9JstackLock.FindStringSubmatch(line)
+	if len(res) == 0 {
+		return false
+	}
+	lock, class := "0x"+res[3], res[2]
+	switch {
+	case res[1] == "locked":
+		jt.LocksOwned = append(jt.LocksOwned, lock)
+		jt.Stack = append(jt.Stack, "\t- locked <"+lock+"> (a "+class+")")
+	case res[1] == "blocked on":
+		jt.LocksWaiting = append(jt.LocksWaiting, lock)
+		jt.Stack = append(jt.Stack, "\t- waiting to lock <"+lock+"> (a "+class+")")
+	case res[1] == "parking to wait for" || ParseFrame(jt.topFrame()).Method == "park":
+		// ThreadInfo prints the synchronizer of a parked thread as waited on.
+		jt.ParkingOn = append(jt.ParkingOn, lock)
+		jt.Stack = append(jt.Stack, "\t- parking to wait for  <"+lock+"> (a "+class+")")
+	default:
+		jt.WaitingOn = append(jt.WaitingOn, lock)
+		jt.Stack = append(jt.Stack, "\t- waiting on <"+lock+"> (a "+class+")")
+	}
+	return true
+}
+
+//addPendingLock adds the lock of a 3XMTHREADBLOCK line to locks, its stack line is added after the top frame.
+func (p *j9Parser) addPendingLock(locks *[]string, line, format string) {
+	class, addr := j9Lock(line)
+	if addr == "" {
+		return
+	}
+	*locks = append(*locks, addr)
+	p.pendingLock = strings.Replace(strings.Replace(format, "%s", addr, 1), "%s", class, 1)
+}
+
+//flushLock adds the pending lock line to the stack of the current thread.
+func (p *j9Parser) flushLock() {
+	if p.pendingLock != "" {
+		p.current.Stack = append(p.current.Stack, p.pendingLock)
+		p.pendingLock = ""
+	}
+}
+
+//flush ends the current thread, it must be called after the last line.
+func (p *j9Parser) flush() {
+	if p.current != nil {
+		p.flushLock()
+	}
+	p.pendingLock = ""
+}
+
+//splitJ9Tag separates the javacore tag, like "4XESTACKTRACE", from the rest of a line.
+//A line without tag is returned trimmed with an empty tag.
+func splitJ9Tag(line string) (string, string) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		i = len(line)
+	}
+	tag := line[:i]
+	if len(tag) < 2 || tag[0] < '0' || tag[0] > '9' {
+		return "", line
+	}
+	for _, c := range tag[1:] {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return "", line
+		}
+	}
+	return tag, strings.TrimSpace(line[i:])
+}
+
+//j9Frame rewrites an OpenJ9 frame like "java/lang/Object.wait(Object.java:167(Compiled Code))"
+//like HotSpot prints it, "java.lang.Object.wait(Object.java:167)".
+func j9Frame(frame string) string {
+	location := ""
+	if i := strings.IndexByte(frame, '('); i >= 0 {
+		frame, location = frame[:i], strings.TrimSuffix(frame[i+1:], ")")
+	}
+	// "(Compiled Code)" or "(Bytecode PC:12)" after the line number.
+	if i := strings.IndexByte(location, '('); i > 0 {
+		location = location[:i]
+	}
+	return strings.Replace(frame, "/", ".", -1) + "(" + location + ")"
+}
+
+//j9Lock returns the class and the address of the first lock of line, like "java/lang/Object@0x00000000E0A1B2C8".
+func j9Lock(line string) (string, string) {
+	res := reJ9Lock.FindStringSubmatch(line)
+	if len(res) == 0 {
+		return "", ""
+	}
+	return strings.Replace(res[1], "/", ".", -1), res[2]
+}
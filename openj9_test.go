@@ -0,0 +1,47 @@
+package jstackparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOpenJ9Javacore(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "openj9_javacore.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jtd.Format != FormatOpenJ9 || jtd.TotalThreads != 4 {
+		t.Fatalf("Format = %s, TotalThreads = %d, want OpenJ9 and 4", jtd.Format, jtd.TotalThreads)
+	}
+	// The native thread ID of the anonymous native thread that follows must not overwrite the one of main.
+	if main := jtd.MainThread(); main == nil || main.NID != "0x3B0A" || main.NativeID != 0x3B0A {
+		t.Errorf("main = %+v, want nid 0x3B0A", main)
+	}
+	if got := jtd.DetectDeadlocks(); !reflect.DeepEqual(got, [][]DeadlockHop{{{"0x0000000000E1C100", "0x00000000E0A1B2C8"}, {"0x0000000000E1D000", "0x00000000E0A1B2D0"}}}) {
+		t.Errorf("DetectDeadlocks() = %v, want the WebContainer threads", got)
+	}
+}
+
+func TestParseOpenJ9Jstack(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "openj9_jstack.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jtd.Format != FormatOpenJ9 || jtd.TotalThreads != 5 {
+		t.Fatalf("Format = %s, TotalThreads = %d, want OpenJ9 and 5", jtd.Format, jtd.TotalThreads)
+	}
+	main := jtd.Threads["#1"]
+	if main == nil || main.Name != "main" || main.Prio != 5 || main.StackDepth != 3 || main.EffectiveStatus() != StatusBlockedNative {
+		t.Errorf("main = %+v", main)
+	}
+	if got := jtd.DetectDeadlocks(); !reflect.DeepEqual(got, [][]DeadlockHop{{{"#31", "0x7a81197d"}, {"#32", "0x5ca881b5"}}}) {
+		t.Errorf("DetectDeadlocks() = %v, want the WebContainer threads", got)
+	}
+	pool := jtd.Threads["#33"]
+	if !reflect.DeepEqual(pool.ParkingOn, []string{"0x24d46ca6"}) || !reflect.DeepEqual(pool.OwnableSynchronizers, []string{"0x4517d9a3"}) {
+		t.Errorf("pool-1-thread-1 ParkingOn = %v, OwnableSynchronizers = %v", pool.ParkingOn, pool.OwnableSynchronizers)
+	}
+	if finalizer := jtd.Threads["#2"]; !reflect.DeepEqual(finalizer.WaitingOn, []string{"0x372f7a8d"}) || !finalizer.IsDaemon {
+		t.Errorf("Finalizer thread = %+v", finalizer)
+	}
+}
@@ -0,0 +1,280 @@
+package jstackparser
+
+import (
+	"crypto/sha256"
+	"hash"
+	"hash/fnv"
+	"regexp"
+	"strings"
+)
+
+//Logger receives the log messages of the parser. *logrus.Logger and *logrus.Entry satisfy it.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+//Options tunes the behavior of ParseJStackWithOptions. The zero value gives the ParseJStack defaults.
+type Options struct {
+	//RetainRaw keeps the original dump text on the returned JavaThreadDump, see JavaThreadDump.Raw.
+	RetainRaw bool
+	//Strict makes the parsing fail on the first thread header or lock line that can't be parsed,
+	//instead of skipping it.
+	Strict bool
+	//HeaderRegexp replaces the built-in regexp matching the thread header lines, the ones starting with
+	//a double quote, to support exotic formats. The fields are taken from its named capture groups:
+	//"name", "number" (like #12), "daemon" (non-empty for daemon threads), "prio", "osprio",
+	//"cpu" (in milliseconds), "elapsed" (in seconds), "tid", "nid" and "status". "name" and "tid" are
+	//required, the threads are keyed by tid.
+	HeaderRegexp *regexp.Regexp
+	//IgnoreFramePatterns excludes the "at" frames matching any of the patterns from the stack hashes,
+	//they are still kept in JavaThread.Stack. They are matched against the frame without "\tat ".
+	IgnoreFramePatterns []*regexp.Regexp
+	//HashAlgo selects the hash function of the stack hashes, HashSHA256 by default.
+	HashAlgo HashAlgo
+	//StackHashFrames limits the stack hashes to the top frames of the stacks when positive, so that threads
+	//doing the same thing from different callers share a hash. The ignored frames don't count.
+	StackHashFrames int
+	//IgnoreLineNumbers hashes the frames without their line number, "(Service.java:42)" becomes
+	//"(Service.java)", so that threads in the same methods at different lines share a hash.
+	IgnoreLineNumbers bool
+	//MaxLines stops the parsing after that many lines when positive, to bound the work on untrusted input.
+	//The dump is analyzed as far as it was read and a problem reports the truncation.
+	MaxLines int
+	//Logger receives the parser log messages. Nothing is logged when nil.
+	Logger Logger
+	//Thresholds tunes the heuristics reporting problems.
+	Thresholds Thresholds
+	//StatusAliases maps the localized Thread.State labels of a JVM running with a non-English locale
+	//to the canonical statuses, like StatusBlocked.
+	StatusAliases map[string]string
+	//Detectors are custom checks run after the built-in analysis, their problems are merged in the results.
+	Detectors []func(*JavaThreadDump) []Problem
+	//ThreadsArray encodes the threads of the dump in JSON as an array in dump order, see OrderedThreads,
+	//instead of an object keyed by tid.
+	ThreadsArray bool
+}
+
+//Thresholds holds the limits used by the analysis to report problems. Zero fields take the default value,
+//except ManyLocksOwned, GCThreadStorm and IdlePoolRatio: their checks are opt-in and zero disables them.
+type Thresholds struct {
+	//DeepStackDepth is the stack depth above which a non RUNNABLE thread is reported. Defaults to 20.
+	DeepStackDepth int
+	//ManyLocksOwned is the number of owned locks from which a thread is reported, like 10.
+	ManyLocksOwned int
+	//GCThreadStorm is the number of GC threads from which the dump is reported, like 64.
+	GCThreadStorm int
+	//LeakGrowthFactor is the growth of a pool or stack above which ThreadLeakReport flags it. Defaults to 2.
+	LeakGrowthFactor float64
+	//LeakMinThreads is the number of threads from which ThreadLeakReport considers a pool or stack, and the
+	//idle pool check a pool. Defaults to 10.
+	LeakMinThreads int
+	//BottleneckWaiters is the number of threads waiting behind a stuck lock holder from which it is reported,
+	//see JavaThreadDump.DetectBottlenecks. Defaults to 5.
+	BottleneckWaiters int
+	//IdlePoolRatio is the fraction of WAITING or TIMED_WAITING threads of a pool from which it is reported as
+	//oversized, among the pools of at least LeakMinThreads threads, like 0.9.
+	IdlePoolRatio float64
+}
+
+var defaultThresholds = Thresholds{
+	DeepStackDepth: 20,
+
+	LeakGrowthFactor: 2,
+	LeakMinThreads:   10,
+
+	BottleneckWaiters: 5,
+}
+
+func (th Thresholds) withDefaults() Thresholds {
+	if th.DeepStackDepth <= 0 {
+		th.DeepStackDepth = defaultThresholds.DeepStackDepth
+	}
+	if th.LeakGrowthFactor <= 0 {
+		th.LeakGrowthFactor = defaultThresholds.LeakGrowthFactor
+	}
+	if th.LeakMinThreads <= 0 {
+		th.LeakMinThreads = defaultThresholds.LeakMinThreads
+	}
+	if th.BottleneckWaiters <= 0 {
+		th.BottleneckWaiters = defaultThresholds.BottleneckWaiters
+	}
+	return th
+}
+
+func (opts *Options) logger() Logger {
+	if opts.Logger == nil {
+		return nopLogger{}
+	}
+	return opts.Logger
+}
+
+//nopLogger discards every message, so that importing the package produces no output.
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+//HashAlgo is a hash function for the stack hashes.
+type HashAlgo int
+
+const (
+	//HashSHA256 hashes the stacks with sha256.
+	HashSHA256 HashAlgo = iota
+	//HashFNV hashes the stacks with the 64-bit FNV-1a, cheaper than sha256 and allocating less, most of all
+	//on the CPUs without sha256 instructions, see BenchmarkAnalyze. The stack hashes only group the threads,
+	//so its higher collision risk is acceptable.
+	HashFNV
+)
+
+//stackHashing holds the Options used to compute the stack hashes of the threads.
+type stackHashing struct {
+	ignoreFrames []*regexp.Regexp
+	algo         HashAlgo
+	frames       int
+	ignoreLines  bool
+}
+
+func (opts *Options) stackHashing() stackHashing {
+	return stackHashing{ignoreFrames: opts.IgnoreFramePatterns, algo: opts.HashAlgo, frames: opts.StackHashFrames, ignoreLines: opts.IgnoreLineNumbers}
+}
+
+func (sh stackHashing) newHash() hash.Hash {
+	if sh.algo == HashFNV {
+		return fnv.New64a()
+	}
+	return sha256.New()
+}
+
+//ignored reports whether frame is excluded from the stack hashes.
+func (sh stackHashing) ignored(frame string) bool {
+	for _, pattern := range sh.ignoreFrames {
+		if pattern.MatchString(frame) {
+			return true
+		}
+	}
+	return false
+}
+
+//normalize returns the stack line as it is hashed.
+func (sh stackHashing) normalize(stackLine string) string {
+	if !sh.ignoreLines || !strings.HasSuffix(stackLine, ")") {
+		return stackLine
+	}
+	i := strings.LastIndexByte(stackLine, ':')
+	if i < 0 || i < strings.LastIndexByte(stackLine, '(') || !isDigits([]byte(stackLine[i+1:len(stackLine)-1])) {
+		return stackLine
+	}
+	return stackLine[:i] + ")"
+}
+
+//Option configures the Options used by ParseJStack.
+type Option func(*Options)
+
+func newOptions(options []Option) Options {
+	var opts Options
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+//WithRetainRaw keeps the original dump text on the returned JavaThreadDump.
+func WithRetainRaw() Option {
+	return func(opts *Options) {
+		opts.RetainRaw = true
+	}
+}
+
+//WithStrict makes the parsing fail on the first malformed thread header or lock line.
+func WithStrict() Option {
+	return func(opts *Options) {
+		opts.Strict = true
+	}
+}
+
+//WithThresholds replaces the thresholds of the analysis heuristics.
+func WithThresholds(th Thresholds) Option {
+	return func(opts *Options) {
+		opts.Thresholds = th
+	}
+}
+
+//WithMaxStackDepth sets the stack depth above which a non RUNNABLE thread is reported,
+//Thresholds.DeepStackDepth, keeping the other thresholds.
+func WithMaxStackDepth(depth int) Option {
+	return func(opts *Options) {
+		opts.Thresholds.DeepStackDepth = depth
+	}
+}
+
+//WithStatusAliases maps localized Thread.State labels to the canonical statuses.
+func WithStatusAliases(aliases map[string]string) Option {
+	return func(opts *Options) {
+		opts.StatusAliases = aliases
+	}
+}
+
+//WithHeaderRegexp matches the thread headers with rx, see Options.HeaderRegexp.
+func WithHeaderRegexp(rx *regexp.Regexp) Option {
+	return func(opts *Options) {
+		opts.HeaderRegexp = rx
+	}
+}
+
+//WithIgnoreFramePatterns excludes the frames matching any of the patterns from the stack hashes.
+func WithIgnoreFramePatterns(patterns ...*regexp.Regexp) Option {
+	return func(opts *Options) {
+		opts.IgnoreFramePatterns = patterns
+	}
+}
+
+//WithHashAlgo selects the hash function of the stack hashes.
+func WithHashAlgo(algo HashAlgo) Option {
+	return func(opts *Options) {
+		opts.HashAlgo = algo
+	}
+}
+
+//WithMaxLines stops the parsing after n lines.
+func WithMaxLines(n int) Option {
+	return func(opts *Options) {
+		opts.MaxLines = n
+	}
+}
+
+//WithDetectors adds custom checks to the analysis.
+func WithDetectors(detectors ...func(*JavaThreadDump) []Problem) Option {
+	return func(opts *Options) {
+		opts.Detectors = append(opts.Detectors, detectors...)
+	}
+}
+
+//WithStackHashFrames limits the stack hashes to the top n frames.
+func WithStackHashFrames(n int) Option {
+	return func(opts *Options) {
+		opts.StackHashFrames = n
+	}
+}
+
+//WithIgnoreLineNumbers hashes the frames without their line number.
+func WithIgnoreLineNumbers() Option {
+	return func(opts *Options) {
+		opts.IgnoreLineNumbers = true
+	}
+}
+
+//WithThreadsArray encodes the threads in JSON as an array in dump order.
+func WithThreadsArray() Option {
+	return func(opts *Options) {
+		opts.ThreadsArray = true
+	}
+}
+
+//WithLogger sends the parser log messages to l.
+func WithLogger(l Logger) Option {
+	return func(opts *Options) {
+		opts.Logger = l
+	}
+}
@@ -0,0 +1,83 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//poolMemberSuffixes are the words naming the threads of a pool before their counter, stripped with it.
+var poolMemberSuffixes = []string{"-thread-", "-worker-"}
+
+//poolName returns the name of the pool of a thread, its name without the trailing counter: "http-nio-8080-exec-12"
+//belongs to "http-nio-8080-exec-". The "-thread-<n>" and "-worker-<n>" suffixes are stripped but their separator,
+//"pool-3-thread-17" belongs to "pool-3-" and "ForkJoinPool.commonPool-worker-9" to "ForkJoinPool.commonPool-".
+//A thread whose name doesn't end with a counter is a pool by itself.
+func poolName(name string) string {
+	pool := strings.TrimRight(name, "0123456789")
+	if pool == name || pool == "" {
+		return name
+	}
+	for _, suffix := range poolMemberSuffixes {
+		if strings.HasSuffix(pool, suffix) && len(pool) > len(suffix) {
+			return pool[:len(pool)-len(suffix)+1]
+		}
+	}
+	return pool
+}
+
+//poolSizes returns the number of threads of each pool.
+func (jtd *JavaThreadDump) poolSizes() map[string]int {
+	sizes := make(map[string]int)
+	for _, jt := range jtd.Threads {
+		sizes[poolName(jt.Name)] += jt.count()
+	}
+	return sizes
+}
+
+//PoolStats counts the threads of a pool.
+type PoolStats struct {
+	Count    int            `json:"count"`
+	ByStatus map[string]int `json:"byStatus"`
+}
+
+func (jtd *JavaThreadDump) threadPools() map[string]*PoolStats {
+	pools := make(map[string]*PoolStats)
+	for _, jt := range jtd.Threads {
+		name := poolName(jt.Name)
+		pool := pools[name]
+		if pool == nil {
+			pool = &PoolStats{ByStatus: make(map[string]int)}
+			pools[name] = pool
+		}
+		pool.Count += jt.count()
+		pool.ByStatus[jt.Status] += jt.count()
+	}
+	return pools
+}
+
+//ThreadLeakReport compares the thread pools and stacks of current with those of baseline, an earlier
+//dump of the same JVM, and reports the ones that grew more than Thresholds.LeakGrowthFactor times,
+//among those with at least Thresholds.LeakMinThreads threads in current. The thresholds are those current
+//was parsed with. The messages are sorted.
+func ThreadLeakReport(baseline, current *JavaThreadDump) []string {
+	th := current.thresholds.withDefaults()
+	grew := func(before, after int) bool {
+		return after >= th.LeakMinThreads && float64(after) > float64(before)*th.LeakGrowthFactor
+	}
+	report := make([]string, 0)
+	before := baseline.poolSizes()
+	for pool, after := range current.poolSizes() {
+		if grew(before[pool], after) {
+			report = append(report, fmt.Sprintf("pool %s grew from %d to %d threads.", pool, before[pool], after))
+		}
+	}
+	representatives := current.stackRepresentatives()
+	for hash, after := range current.ByStack {
+		if grew(baseline.ByStack[hash], after) {
+			report = append(report, fmt.Sprintf("stack %s grew from %d to %d threads like %s.", hash, baseline.ByStack[hash], after, representatives[hash].Name))
+		}
+	}
+	sort.Strings(report)
+	return report
+}
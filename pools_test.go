@@ -0,0 +1,23 @@
+package jstackparser
+
+import "testing"
+
+func TestPoolName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"http-nio-8080-exec-42", "http-nio-8080-exec-"},
+		{"pool-3-thread-17", "pool-3-"},
+		{"ForkJoinPool.commonPool-worker-9", "ForkJoinPool.commonPool-"},
+		{"GC task thread#0 (ParallelGC)", "GC task thread#0 (ParallelGC)"},
+		{"C2 CompilerThread1", "C2 CompilerThread"},
+		{"thread-5", "thread-"},
+		{"main", "main"},
+		{"42", "42"},
+	}
+	for _, tt := range tests {
+		if got := poolName(tt.name); got != tt.want {
+			t.Errorf("poolName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package jstackparser
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+//ThreadsInMethod returns the threads having any "at" frame that contains substr, sorted by name.
+func (jtd *JavaThreadDump) ThreadsInMethod(substr string) []*JavaThread {
+	return jtd.threadsInMethod(substr, false)
+}
+
+//ThreadsInMethodIgnoreCase is like ThreadsInMethod but matches substr case-insensitively.
+func (jtd *JavaThreadDump) ThreadsInMethodIgnoreCase(substr string) []*JavaThread {
+	return jtd.threadsInMethod(substr, true)
+}
+
+func (jtd *JavaThreadDump) threadsInMethod(substr string, ignoreCase bool) []*JavaThread {
+	if ignoreCase {
+		substr = strings.ToLower(substr)
+	}
+	res := make([]*JavaThread, 0)
+	for _, jt := range jtd.Threads {
+		for _, stackLine := range jt.Stack {
+			if !strings.HasPrefix(stackLine, "\tat ") {
+				continue
+			}
+			if ignoreCase {
+				stackLine = strings.ToLower(stackLine)
+			}
+			if strings.Contains(stackLine[4:], substr) {
+				res = append(res, jt)
+				break
+			}
+		}
+	}
+	sortThreadsByName(res)
+	return res
+}
+
+//ThreadsWithStatus returns the threads in any of the statuses, sorted by name.
+func (jtd *JavaThreadDump) ThreadsWithStatus(statuses ...string) []*JavaThread {
+	wanted := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		wanted[status] = true
+	}
+	return jtd.Filter(func(jt *JavaThread) bool { return wanted[jt.Status] })
+}
+
+//ThreadsWithNamePrefix returns the threads whose name starts with prefix, sorted by name.
+func (jtd *JavaThreadDump) ThreadsWithNamePrefix(prefix string) []*JavaThread {
+	return jtd.Filter(func(jt *JavaThread) bool { return strings.HasPrefix(jt.Name, prefix) })
+}
+
+//Filter returns the threads for which pred returns true, sorted by name, like the BLOCKED threads of a pool:
+//
+//	jtd.Filter(func(jt *JavaThread) bool {
+//		return jt.Status == StatusBlocked && strings.HasPrefix(jt.Name, "http-nio-")
+//	})
+func (jtd *JavaThreadDump) Filter(pred func(*JavaThread) bool) []*JavaThread {
+	res := make([]*JavaThread, 0)
+	for _, jt := range jtd.Threads {
+		if pred(jt) {
+			res = append(res, jt)
+		}
+	}
+	sortThreadsByName(res)
+	return res
+}
+
+//ThreadsByDepth returns all the threads sorted by descending stack depth, then by name.
+func (jtd *JavaThreadDump) ThreadsByDepth() []*JavaThread {
+	res := make([]*JavaThread, 0, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		res = append(res, jt)
+	}
+	sortThreadsByName(res)
+	sort.SliceStable(res, func(i, j int) bool { return res[i].StackDepth > res[j].StackDepth })
+	return res
+}
+
+//OrderedThreads returns all the threads in the order of the dump. The threads inserted by
+//AddThreadAndReanalyze come after the parsed ones, and the threads of a collapsed dump are in the
+//order of their representative. The threads without a position, like the ones put directly in Threads,
+//come last sorted by name.
+func (jtd *JavaThreadDump) OrderedThreads() []*JavaThread {
+	res := make([]*JavaThread, 0, len(jtd.Threads))
+	for _, jt := range jtd.Threads {
+		res = append(res, jt)
+	}
+	sortThreadsByName(res)
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].seq == 0 || res[j].seq == 0 {
+			return res[j].seq == 0 && res[i].seq != 0
+		}
+		return res[i].seq < res[j].seq
+	})
+	return res
+}
+
+//sortThreadsByName sorts the threads by name, using the tid to break ties.
+func sortThreadsByName(jts []*JavaThread) {
+	sort.Slice(jts, func(i, j int) bool {
+		if jts[i].Name != jts[j].Name {
+			return jts[i].Name < jts[j].Name
+		}
+		return jts[i].TID < jts[j].TID
+	})
+}
+
+//HottestPackage returns the most common package among the top frames of the RUNNABLE threads
+//and how many of them have it on top. Ties are broken by package name.
+func (jtd *JavaThreadDump) HottestPackage() (string, int) {
+	counts := make(map[string]int)
+	for _, jt := range jtd.Threads {
+		if jt.Status != StatusRunnable {
+			continue
+		}
+		if pkg := ParseFrame(jt.topFrame()).Package(); pkg != "" {
+			counts[pkg]++
+		}
+	}
+	hottest, max := "", 0
+	for pkg, count := range counts {
+		if count > max || (count == max && pkg < hottest) {
+			hottest, max = pkg, count
+		}
+	}
+	return hottest, max
+}
+
+//topFrame returns the first "at" frame of the stack without the "\tat " prefix, or "" when there is none.
+func (jt *JavaThread) topFrame() string {
+	for _, stackLine := range jt.Stack {
+		if strings.HasPrefix(stackLine, "\tat ") {
+			return stackLine[4:]
+		}
+	}
+	return ""
+}
+
+//bottomFrame returns the last "at" frame of the stack without the "\tat " prefix, or "" when there is none.
+func (jt *JavaThread) bottomFrame() string {
+	for i := len(jt.Stack) - 1; i >= 0; i-- {
+		if strings.HasPrefix(jt.Stack[i], "\tat ") {
+			return jt.Stack[i][4:]
+		}
+	}
+	return ""
+}
+
+//entryMethods are the methods found at the bottom of a complete stack: Thread.run and its overrides,
+//the main method, and the continuation entry of the virtual threads.
+var entryMethods = map[string]bool{"run": true, "main": true, "enter": true, "enter0": true, "enterSpecial": true}
+
+//truncated tells if the stack has a "..." marker or doesn't end with an entry method.
+//A thread without any frame isn't truncated.
+func (jt *JavaThread) truncated() bool {
+	for _, stackLine := range jt.Stack {
+		if strings.HasPrefix(strings.TrimSpace(stackLine), "...") {
+			return true
+		}
+	}
+	bottom := jt.bottomFrame()
+	return bottom != "" && !entryMethods[ParseFrame(bottom).Method]
+}
+
+//StartedAt returns when the thread started, dumpTime minus its ElapsedSec, usually called with the
+//Timestamp of the dump. It returns the zero time when the header had no "elapsed=" field.
+func (jt *JavaThread) StartedAt(dumpTime time.Time) time.Time {
+	if jt.ElapsedSec == 0 {
+		return time.Time{}
+	}
+	return dumpTime.Add(-time.Duration(jt.ElapsedSec * float64(time.Second)))
+}
+
+//MainThread returns the thread named "main", or else the first by name of the threads flagged IsMain,
+//or nil when there is none. "DestroyJavaVM" takes over once main returns and is not the main thread.
+func (jtd *JavaThreadDump) MainThread() *JavaThread {
+	candidates := make([]*JavaThread, 0)
+	for _, jt := range jtd.Threads {
+		if jt.IsMain {
+			candidates = append(candidates, jt)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sortThreadsByName(candidates)
+	for _, jt := range candidates {
+		if jt.Name == "main" {
+			return jt
+		}
+	}
+	return candidates[0]
+}
+
+//blockingNativeMethods are the native methods of the JDK that wait in a system call, like a socket read.
+//The JVM reports the threads inside them as RUNNABLE although they don't use any CPU.
+var blockingNativeMethods = map[string]bool{
+	"socketRead0": true, "socketAccept": true, "accept0": true, "accept": true, "connect0": true,
+	"read": true, "read0": true, "readBytes": true, "receive0": true, "recvfrom": true, "peekData": true,
+	"poll": true, "poll0": true, "epollWait": true, "kevent0": true, "wait0": true, "doSelect": true,
+}
+
+//EffectiveStatus returns the status of the thread, except for a RUNNABLE thread with a blocking native
+//method like socketRead0 or epollWait on top of its stack: it returns StatusBlockedNative,
+//since such a thread waits for I/O rather than burning CPU.
+func (jt *JavaThread) EffectiveStatus() string {
+	if jt.Status != StatusRunnable {
+		return jt.Status
+	}
+	frame := ParseFrame(jt.topFrame())
+	if frame.Source == "Native Method" && blockingNativeMethods[frame.Method] {
+		return StatusBlockedNative
+	}
+	return jt.Status
+}
+
+//ThreadByNID returns the thread with the given jstack nid, like "0x6b9e", or nil when there is none.
+//Like ThreadByOSThreadID, it also finds the system threads.
+func (jtd *JavaThreadDump) ThreadByNID(nid string) *JavaThread {
+	id, err := parseNID(nid)
+	if err != nil {
+		for _, jts := range []map[string]*JavaThread{jtd.Threads, jtd.SystemThreads} {
+			for _, jt := range jts {
+				if jt.NID == nid {
+					return jt
+				}
+			}
+		}
+		return nil
+	}
+	return jtd.ThreadByOSThreadID(id)
+}
+
+//ThreadByOSThreadID returns the thread with the given decimal OS thread id, as shown by "top -H", or nil when there is none.
+//The system threads are searched too: the VM, GC and compiler threads are often the hot ones.
+func (jtd *JavaThreadDump) ThreadByOSThreadID(osTID int64) *JavaThread {
+	for _, jts := range []map[string]*JavaThread{jtd.Threads, jtd.SystemThreads} {
+		for _, jt := range jts {
+			if jt.NativeID == osTID && jt.NID != "" {
+				return jt
+			}
+		}
+	}
+	return nil
+}
+
+//CompilerThreads returns the number of JIT compiler threads and how many of them are RUNNABLE.
+//All of them busy at once hints at a compilation storm, like a deoptimization loop.
+//The parsed compiler threads are in SystemThreads, the ones added to Threads are also counted.
+func (jtd *JavaThreadDump) CompilerThreads() (int, int) {
+	count, runnable := 0, 0
+	for _, jts := range []map[string]*JavaThread{jtd.SystemThreads, jtd.Threads} {
+		for _, jt := range jts {
+			if jt.IsCompiler {
+				count++
+				if jt.Status == StatusRunnable {
+					runnable++
+				}
+			}
+		}
+	}
+	return count, runnable
+}
+
+//ByGroup returns the threads of each thread group, sorted by name.
+//Threads without a group in the dump are left out.
+func (jtd *JavaThreadDump) ByGroup() map[string][]*JavaThread {
+	groups := make(map[string][]*JavaThread)
+	for _, jt := range jtd.Threads {
+		if jt.ThreadGroup != "" {
+			groups[jt.ThreadGroup] = append(groups[jt.ThreadGroup], jt)
+		}
+	}
+	for _, jts := range groups {
+		sortThreadsByName(jts)
+	}
+	return groups
+}
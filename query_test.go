@@ -0,0 +1,47 @@
+package jstackparser
+
+import "testing"
+
+func TestEffectiveStatus(t *testing.T) {
+	tests := []struct {
+		status, frame, want string
+	}{
+		{StatusRunnable, "\tat java.net.SocketInputStream.socketRead0(Native Method)", StatusBlockedNative},
+		{StatusRunnable, "\tat sun.nio.ch.SocketDispatcher.read0(java.base@17/Native Method)", StatusBlockedNative},
+		{StatusRunnable, "\tat java.base@17/sun.nio.ch.EPoll.wait(Native Method)", StatusRunnable},
+		{StatusRunnable, "\tat com.acme.Parser.read(Parser.java:12)", StatusRunnable},
+		{StatusWaiting, "\tat sun.nio.ch.SocketDispatcher.read0(java.base@17/Native Method)", StatusWaiting},
+	}
+	for _, tt := range tests {
+		jt := &JavaThread{Status: tt.status, Stack: []string{tt.frame}}
+		if got := jt.EffectiveStatus(); got != tt.want {
+			t.Errorf("EffectiveStatus() of %s %q = %s, want %s", tt.status, tt.frame, got, tt.want)
+		}
+	}
+}
+
+func TestThreadByNID(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "jdk8.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		nid  string
+		want string
+	}{
+		{"0x6b9e", "http-nio-8080-exec-1"},
+		{"0x6b85", "VM Thread"},
+		{"0x6b80", "GC task thread#0 (ParallelGC)"},
+	}
+	for _, tt := range tests {
+		if jt := jtd.ThreadByNID(tt.nid); jt == nil || jt.Name != tt.want {
+			t.Errorf("ThreadByNID(%q) = %v, want %s", tt.nid, jt, tt.want)
+		}
+	}
+	if jt := jtd.ThreadByOSThreadID(0x6b80); jt == nil || jt.Name != "GC task thread#0 (ParallelGC)" {
+		t.Errorf("ThreadByOSThreadID(%d) = %v, want the GC task thread", 0x6b80, jt)
+	}
+	if jt := jtd.ThreadByNID("0x1"); jt != nil {
+		t.Errorf("ThreadByNID(\"0x1\") = %s, want nil", jt.Name)
+	}
+}
@@ -0,0 +1,97 @@
+package jstackparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Summary returns a short human readable description of the dump: date, JVM, and threads by status.
+func (jtd *JavaThreadDump) Summary() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Date: %s\n", jtd.Date)
+	fmt.Fprintf(&sb, "JVM: %s\n", jtd.VersionString)
+	fmt.Fprintf(&sb, "Threads: %d\n", jtd.TotalThreads)
+	statuses := make([]string, 0, len(jtd.ByStatus))
+	for status := range jtd.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&sb, "  %s: %d\n", status, jtd.ByStatus[status])
+	}
+	fmt.Fprintf(&sb, "Unique stacks: %d\n", len(jtd.ByStack))
+	return sb.String()
+}
+
+//AnalyzeString parses a jstack output and returns its Summary followed by its Problems.
+func AnalyzeString(jstackStr string, options ...Option) (string, error) {
+	jtd, err := ParseJStack(jstackStr, options...)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString(jtd.Summary())
+	fmt.Fprintf(&sb, "Problems: %d\n", len(jtd.Problems))
+	for _, problem := range jtd.Problems {
+		fmt.Fprintf(&sb, "  - %s\n", problem)
+	}
+	return sb.String(), nil
+}
+
+//markdownTopStacks is the number of stack groups printed by ToMarkdown.
+const markdownTopStacks = 10
+
+//ToMarkdown returns a GitHub flavored Markdown report of the dump: a table of the threads by status,
+//the most common stacks with their thread count, and the problems.
+func (jtd *JavaThreadDump) ToMarkdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## Thread dump %s\n\n", jtd.Date)
+	if jtd.VersionString != "" {
+		fmt.Fprintf(&sb, "%s\n\n", jtd.VersionString)
+	}
+	sb.WriteString("| Status | Threads |\n|---|---:|\n")
+	statuses := make([]string, 0, len(jtd.ByStatus))
+	for status := range jtd.ByStatus {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(&sb, "| %s | %d |\n", status, jtd.ByStatus[status])
+	}
+	fmt.Fprintf(&sb, "| **Total** | %d |\n", jtd.TotalThreads)
+
+	representatives := jtd.stackRepresentatives()
+	hashes := make([]string, 0, len(jtd.ByStack))
+	for hash := range jtd.ByStack {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		if jtd.ByStack[hashes[i]] != jtd.ByStack[hashes[j]] {
+			return jtd.ByStack[hashes[i]] > jtd.ByStack[hashes[j]]
+		}
+		return hashes[i] < hashes[j]
+	})
+	if len(hashes) > markdownTopStacks {
+		hashes = hashes[:markdownTopStacks]
+	}
+	sb.WriteString("\n### Top stacks\n")
+	for _, hash := range hashes {
+		jt := representatives[hash]
+		fmt.Fprintf(&sb, "\n**%d threads** like `%s` (%s)\n\n```\n", jtd.ByStack[hash], jt.Name, jt.Status)
+		for _, stackLine := range jt.Stack {
+			sb.WriteString(strings.TrimPrefix(stackLine, "\t"))
+			sb.WriteString("\n")
+		}
+		sb.WriteString("```\n")
+	}
+
+	sb.WriteString("\n### Problems\n\n")
+	if len(jtd.Problems) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for _, problem := range jtd.Problems {
+		fmt.Fprintf(&sb, "- %s\n", problem)
+	}
+	return sb.String()
+}
@@ -0,0 +1,250 @@
+package jstackparser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//DominantStack returns the most common stack hash, how many threads have it and their fraction
+//of the total threads. Ties are broken by hash.
+func (jtd *JavaThreadDump) DominantStack() (string, int, float64) {
+	hash, count := "", 0
+	for h, c := range jtd.ByStack {
+		if c > count || (c == count && h < hash) {
+			hash, count = h, c
+		}
+	}
+	if jtd.TotalThreads == 0 {
+		return hash, count, 0
+	}
+	return hash, count, float64(count) / float64(jtd.TotalThreads)
+}
+
+//StackHashes returns the sorted distinct stack hashes of the threads.
+func (jtd *JavaThreadDump) StackHashes() []string {
+	hashes := make([]string, 0, len(jtd.ByStack))
+	for hash := range jtd.ByStack {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+//StackConcentration returns the number of distinct stacks and the Gini coefficient of the number of
+//threads per stack: 0 when the threads are evenly spread over the stacks, close to 1 when a few stacks
+//gather most of them. A dump with a single stack has a coefficient of 0.
+func (jtd *JavaThreadDump) StackConcentration() (int, float64) {
+	counts := make([]int, 0, len(jtd.ByStack))
+	total := 0
+	for _, count := range jtd.ByStack {
+		counts = append(counts, count)
+		total += count
+	}
+	n := len(counts)
+	if n == 0 || total == 0 {
+		return n, 0
+	}
+	sort.Ints(counts)
+	weighted := 0
+	for i, count := range counts {
+		weighted += (i + 1) * count
+	}
+	return n, 2*float64(weighted)/float64(n*total) - float64(n+1)/float64(n)
+}
+
+//threadEntryFrames are the methods at the bottom of nearly every thread, that CommonBottleneck ignores.
+var threadEntryFrames = map[string]bool{
+	"java.lang.Thread.run":                                     true,
+	"java.lang.Thread.runWith":                                 true,
+	"java.util.concurrent.ThreadPoolExecutor$Worker.run":       true,
+	"java.util.concurrent.ThreadPoolExecutor.runWorker":        true,
+	"java.util.concurrent.FutureTask.run":                      true,
+	"java.util.concurrent.Executors$RunnableAdapter.call":      true,
+	"java.util.concurrent.ForkJoinWorkerThread.run":            true,
+	"java.util.concurrent.ForkJoinPool.runWorker":              true,
+	"java.util.concurrent.ForkJoinPool$WorkQueue.topLevelExec": true,
+}
+
+//CommonBottleneck returns the frame found in the stacks of the most threads, without the "at ",
+//and the number of these threads. The thread entry points like Thread.run are ignored. Ties are
+//broken by the frame nearest the top of a stack, then by the frame.
+func (jtd *JavaThreadDump) CommonBottleneck() (string, int) {
+	counts := make(map[string]int)
+	tops := make(map[string]int)
+	for _, jt := range jtd.Threads {
+		seen := make(map[string]bool)
+		depth := 0
+		for _, stackLine := range jt.Stack {
+			if !strings.HasPrefix(stackLine, "\tat ") {
+				continue
+			}
+			frame := stackLine[4:]
+			depth++
+			if seen[frame] {
+				continue
+			}
+			seen[frame] = true
+			if f := ParseFrame(frame); threadEntryFrames[f.Class+"."+f.Method] {
+				continue
+			}
+			counts[frame]++
+			if top, ok := tops[frame]; !ok || depth < top {
+				tops[frame] = depth
+			}
+		}
+	}
+	bottleneck, max := "", 0
+	for frame, count := range counts {
+		if count > max || (count == max && (tops[frame] < tops[bottleneck] || (tops[frame] == tops[bottleneck] && frame < bottleneck))) {
+			bottleneck, max = frame, count
+		}
+	}
+	return bottleneck, max
+}
+
+//Collapse returns a compact copy of the dump where the threads sharing a stack hash, a status and a
+//logical stack hash are replaced by a single thread, a copy of the first of them by name, with their
+//number in Multiplicity and their tids in MemberTIDs. A lock owner and its waiters in the same method
+//so stay apart. TotalThreads, ByStack, ByStatus and the ratios keep their values, the locks are those
+//of the representative threads. The raw output isn't kept.
+func (jtd *JavaThreadDump) Collapse() *JavaThreadDump {
+	byHash := make(map[string][]*JavaThread)
+	for _, jt := range jtd.Threads {
+		key := jt.StackHash + " " + jt.Status + " " + jt.LogicalStackHash
+		byHash[key] = append(byHash[key], jt)
+	}
+	collapsed := &JavaThreadDump{
+		Date:          jtd.Date,
+		Timestamp:     jtd.Timestamp,
+		VersionString: jtd.VersionString,
+		Format:        jtd.Format,
+		SystemThreads: make(map[string]*JavaThread, len(jtd.SystemThreads)),
+		JNIGlobalRefs: jtd.JNIGlobalRefs,
+		Threads:       make(map[string]*JavaThread, len(byHash)),
+		ParseWarnings: append([]string(nil), jtd.ParseWarnings...),
+		thresholds:    jtd.thresholds,
+		hashing:       jtd.hashing,
+		detectors:     jtd.detectors,
+		truncatedAt:   jtd.truncatedAt,
+		threadsArray:  jtd.threadsArray,
+	}
+	for tid, jt := range jtd.SystemThreads {
+		collapsed.SystemThreads[tid] = jt.copy()
+	}
+	for _, members := range byHash {
+		sortThreadsByName(members)
+		rep := members[0].copy()
+		rep.Multiplicity = 0
+		rep.MemberTIDs = make([]string, 0, len(members))
+		for _, jt := range members {
+			rep.Multiplicity += jt.count()
+			if len(jt.MemberTIDs) > 0 {
+				rep.MemberTIDs = append(rep.MemberTIDs, jt.MemberTIDs...)
+			} else {
+				rep.MemberTIDs = append(rep.MemberTIDs, jt.TID)
+			}
+		}
+		sort.Strings(rep.MemberTIDs)
+		collapsed.Threads[rep.TID] = rep
+	}
+	collapsed.Analyze()
+	return collapsed
+}
+
+//copy returns a copy of the thread that shares none of its slices.
+func (jt *JavaThread) copy() *JavaThread {
+	c := *jt
+	for _, slice := range []*[]string{&c.Stack, &c.LocksOwned, &c.LocksWaiting, &c.WaitingOn, &c.LocksReacquiring, &c.ParkingOn, &c.OwnableSynchronizers, &c.MemberTIDs} {
+		if *slice != nil {
+			*slice = append([]string{}, *slice...)
+		}
+	}
+	return &c
+}
+
+//stackRepresentatives maps each stack hash to the first thread by name having that stack.
+func (jtd *JavaThreadDump) stackRepresentatives() map[string]*JavaThread {
+	representatives := make(map[string]*JavaThread)
+	for _, jt := range jtd.Threads {
+		if r := representatives[jt.StackHash]; r == nil || jt.Name < r.Name || (jt.Name == r.Name && jt.TID < r.TID) {
+			representatives[jt.StackHash] = jt
+		}
+	}
+	return representatives
+}
+
+//StackGroup is the threads sharing a stack hash.
+type StackGroup struct {
+	Hash  string `json:"hash"`
+	Count int    `json:"count"`
+	//Stack is the stack of the first thread by name of the group.
+	Stack []string `json:"stack"`
+	//TIDs are the sorted tids of the threads of the group.
+	TIDs []string `json:"tids"`
+}
+
+func (jtd *JavaThreadDump) stackGroups() []StackGroup {
+	byHash := make(map[string]*StackGroup, len(jtd.ByStack))
+	for tid, jt := range jtd.Threads {
+		group := byHash[jt.StackHash]
+		if group == nil {
+			group = &StackGroup{Hash: jt.StackHash, TIDs: make([]string, 0)}
+			byHash[jt.StackHash] = group
+		}
+		group.Count += jt.count()
+		if len(jt.MemberTIDs) > 0 {
+			group.TIDs = append(group.TIDs, jt.MemberTIDs...)
+		} else {
+			group.TIDs = append(group.TIDs, tid)
+		}
+	}
+	groups := make([]StackGroup, 0, len(byHash))
+	for hash, jt := range jtd.stackRepresentatives() {
+		group := byHash[hash]
+		group.Stack = jt.Stack
+		sort.Strings(group.TIDs)
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Hash < groups[j].Hash
+	})
+	return groups
+}
+
+//FoldedStacks returns the stacks in the folded format of the FlameGraph tools: one line per stack
+//with its frames from the bottom to the top joined by ";", a space and its thread count from ByStack.
+//The frames are printed as "Class.method", without the module and the source location, so the stacks
+//only differing by line numbers are merged into one line. The threads without frames are left out
+//and the lines are sorted.
+func (jtd *JavaThreadDump) FoldedStacks() string {
+	counts := make(map[string]int)
+	for hash, jt := range jtd.stackRepresentatives() {
+		frames := make([]string, 0, jt.StackDepth)
+		for i := len(jt.Stack) - 1; i >= 0; i-- {
+			if strings.HasPrefix(jt.Stack[i], "\tat ") {
+				frame := ParseFrame(jt.Stack[i])
+				frames = append(frames, frame.Class+"."+frame.Method)
+			}
+		}
+		if len(frames) > 0 {
+			counts[strings.Join(frames, ";")] += jtd.ByStack[hash]
+		}
+	}
+	lines := make([]string, 0, len(counts))
+	for folded := range counts {
+		lines = append(lines, folded)
+	}
+	sort.Strings(lines)
+	var sb strings.Builder
+	for _, folded := range lines {
+		sb.WriteString(folded)
+		sb.WriteString(" ")
+		sb.WriteString(strconv.Itoa(counts[folded]))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+package jstackparser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollapseCopiesSystemThreads(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "jdk8.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmThread := jtd.SystemThreads["0x00007f5c7c0f3000"]
+	if vmThread == nil {
+		t.Fatal("VM Thread not in SystemThreads")
+	}
+	jtd.Collapse().AnonymizeAddresses()
+	if vmThread.TID != "0x00007f5c7c0f3000" || vmThread.NID != "0x6b85" || jtd.SystemThreads["0x00007f5c7c0f3000"] != vmThread {
+		t.Errorf("anonymizing the collapsed dump changed the VM Thread of the original to %s nid=%s", vmThread.TID, vmThread.NID)
+	}
+}
+
+func TestCollapseKeepsLockOwners(t *testing.T) {
+	// The owner and its waiters are in the same method, so they share a stack hash.
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"worker-1" #21 prio=5 os_prio=0 tid=0x00007f5c7c4b6000 nid=0x6b9e runnable [0x00007f5c2a7f6000]
+   java.lang.Thread.State: RUNNABLE
+	at com.acme.Service.doWork(Service.java:42)
+	- locked <0x0000000000000099> (a java.lang.Object)
+	at java.lang.Thread.run(Thread.java:748)
+
+"worker-2" #22 prio=5 os_prio=0 tid=0x00007f5c7c4b7000 nid=0x6b9f waiting for monitor entry [0x00007f5c2a6f5000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.Service.doWork(Service.java:42)
+	- waiting to lock <0x0000000000000099> (a java.lang.Object)
+	at java.lang.Thread.run(Thread.java:748)
+
+"worker-3" #23 prio=5 os_prio=0 tid=0x00007f5c7c4b8000 nid=0x6ba0 waiting for monitor entry [0x00007f5c2a5f4000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.Service.doWork(Service.java:42)
+	- waiting to lock <0x0000000000000099> (a java.lang.Object)
+	at java.lang.Thread.run(Thread.java:748)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collapsed := jtd.Collapse()
+	if len(collapsed.Threads) != 2 {
+		t.Errorf("%d collapsed threads, want the owner and a waiter", len(collapsed.Threads))
+	}
+	if !reflect.DeepEqual(collapsed.ByStatus, jtd.ByStatus) {
+		t.Errorf("collapsed ByStatus = %v, want %v", collapsed.ByStatus, jtd.ByStatus)
+	}
+	// A problem is reported for each waiter, the collapsed dump has one of each kind.
+	categories := func(jtd *JavaThreadDump) map[string]bool {
+		c := make(map[string]bool)
+		for _, p := range jtd.Analysis.Problems {
+			c[p.Category] = true
+		}
+		return c
+	}
+	if got, want := categories(collapsed), categories(jtd); len(want) == 0 || !reflect.DeepEqual(got, want) {
+		t.Errorf("collapsed problems of categories %v, want %v", got, want)
+	}
+	if got := collapsed.LockContention(); len(got) != 1 {
+		t.Errorf("collapsed LockContention() = %v, want the lock of worker-1", got)
+	}
+}
@@ -0,0 +1,179 @@
+package jstackparser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//threadParser parses the lines of the thread blocks of a dump, from the header to the locked ownable synchronizers.
+//The plain and the long listing (jstack -l) outputs only differ by that last section: it fills OwnableSynchronizers
+//and ends at the next header, so the stack and lock fields are the same with both.
+type threadParser struct {
+	opts           Options
+	headerRe       *regexp.Regexp
+	fields         headerFields
+	noNumberFields headerFields
+	warnings       *[]string
+	current        *JavaThread
+	// inSynchronizers is set in the "Locked ownable synchronizers:" section of the current thread.
+	inSynchronizers bool
+	// hasState is set when the current thread has a Thread.State line.
+	hasState bool
+	// skipping is set after a header that couldn't be parsed, its lines are dropped until the next header.
+	skipping bool
+}
+
+func newThreadParser(opts Options, logger Logger, warnings *[]string) *threadParser {
+	compileRegexps(logger)
+	headerRe := re
+	if opts.HeaderRegexp != nil {
+		headerRe = opts.HeaderRegexp
+	}
+	return &threadParser{
+		opts:           opts,
+		headerRe:       headerRe,
+		fields:         newHeaderFields(headerRe),
+		noNumberFields: newHeaderFields(reHeaderNoNumber),
+		warnings:       warnings,
+		current:        newJavaThread(),
+	}
+}
+
+//parseLine parses the non-empty line b of a thread block, lineNo being its 1-based number for the messages.
+//It returns the thread when b is a header that was parsed, and whether b was used.
+//The error is only returned in Strict mode.
+func (p *threadParser) parseLine(b []byte, lineNo int) (*JavaThread, bool, error) {
+	currJT := p.current
+	if b[0] == '"' {
+		p.flush()
+		p.inSynchronizers, p.hasState, p.skipping = false, false, false
+		currJT = newJavaThread()
+		p.current = currJT
+		header, group := extractGroup(string(b))
+		fields := p.fields
+		res := p.headerRe.FindStringSubmatch(header)
+		if len(res) == 0 {
+			fields, res = p.noNumberFields, reHeaderNoNumber.FindStringSubmatch(header)
+		}
+		if len(res) > 0 {
+			currJT.ThreadGroup = group
+			if err := fields.apply(currJT, res); err != nil {
+				*p.warnings = append(*p.warnings, fmt.Sprintf("line %d: invalid nid %q: %v", lineNo, currJT.NID, err))
+			}
+			return currJT, true, nil
+		} else if p.opts.Strict {
+			return nil, false, fmt.Errorf("line %d: couldn't parse the thread header: %s", lineNo, b)
+		}
+		p.skipping = true
+		*p.warnings = append(*p.warnings, fmt.Sprintf("line %d: couldn't parse the thread header: %s", lineNo, b))
+		return nil, false, nil
+	} else if p.skipping {
+		return nil, false, nil
+	} else if state := bytes.TrimLeft(b, " \t"); bytes.HasPrefix(state, prefixThreadState) {
+		// Usually indented with three spaces, but reformatted logs use tabs or other widths.
+		p.hasState = true
+		res := reStatus.FindSubmatch(state)
+		if len(res) > 0 {
+			line := string(state[len(prefixThreadState):])
+			currJT.Status = canonicalStatus(string(res[1]), line, p.opts.StatusAliases)
+			currJT.StatusDetail = statusDetail(line)
+		}
+	} else if bytes.HasPrefix(b, prefixOwnableSynchronizers) {
+		p.inSynchronizers = true
+	} else if p.inSynchronizers && bytes.HasPrefix(b, prefixSynchronizerItem) {
+		// "- None" when the thread holds no ownable synchronizer.
+		if res := reSynchronizer.FindSubmatch(b); len(res) > 0 {
+			currJT.OwnableSynchronizers = append(currJT.OwnableSynchronizers, string(res[1]))
+		}
+	} else if b[0] == '\t' {
+		// The lock lines belong to the thread wherever they are in the stack, even before the first frame.
+		line := string(b)
+		currJT.Stack = append(currJT.Stack, line)
+		var err error
+		if bytes.HasPrefix(b, prefixLocked) {
+			err = p.addLock(&currJT.LocksOwned, reLock, line, lineNo, "lock ID")
+		} else if bytes.HasPrefix(b, prefixWaitingToLock) {
+			err = p.addLock(&currJT.LocksWaiting, reWLock, line, lineNo, "wait lock ID")
+		} else if bytes.HasPrefix(b, prefixReLock) {
+			err = p.addLock(&currJT.LocksReacquiring, reRLock, line, lineNo, "re-lock ID")
+		} else if bytes.HasPrefix(b, prefixParking) {
+			err = p.addLock(&currJT.ParkingOn, reParking, line, lineNo, "parking lock ID")
+		} else if bytes.HasPrefix(b, prefixWaitingOn) {
+			err = p.addLock(&currJT.WaitingOn, reWaitingOn, line, lineNo, "wait object ID")
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		return nil, false, nil
+	}
+	return nil, true, nil
+}
+
+//flush ends the current thread, it must be called after the last line. A thread without Thread.State line
+//nor frame is a VM internal thread, like "VM Thread" or the GC threads, which has no Java thread.
+func (p *threadParser) flush() {
+	jt := p.current
+	if jt.Name == "" || p.hasState || jt.topFrame() != "" {
+		return
+	}
+	jt.vmInternal = true
+	if status, ok := vmThreadStatuses[jt.Status]; ok {
+		// The status of the header is all there is.
+		jt.Status = status
+	}
+}
+
+//addLock appends the lock address of line to locks, what names the kind of lock in the errors.
+//A line without an address is reported in the parse warnings, or as an error in Strict mode.
+func (p *threadParser) addLock(locks *[]string, rx *regexp.Regexp, line string, lineNo int, what string) error {
+	res := rx.FindStringSubmatch(line)
+	if len(res) == 0 && p.opts.Strict {
+		return fmt.Errorf("line %d: couldn't find the %s: %s", lineNo, what, line)
+	} else if len(res) == 0 {
+		*p.warnings = append(*p.warnings, fmt.Sprintf("line %d: couldn't find the %s: %s", lineNo, what, line))
+	} else if res[1] != noObjectReference {
+		*locks = append(*locks, res[1])
+	}
+	return nil
+}
+
+//ParseThread parses the block of a single thread, from its header line to its stack and locked ownable
+//synchronizers, like it is printed in a jstack output, and analyzes it. Blank lines are ignored.
+//It fails when the block doesn't hold exactly one thread header.
+func ParseThread(block string, options ...Option) (*JavaThread, error) {
+	opts := newOptions(options)
+	warnings := make([]string, 0)
+	p := newThreadParser(opts, opts.logger(), &warnings)
+	var jt *JavaThread
+	scanner := bufio.NewScanner(strings.NewReader(block))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for i := 0; scanner.Scan(); i++ {
+		b := bytes.TrimRight(scanner.Bytes(), "\x00")
+		if len(b) == 0 {
+			continue
+		}
+		parsed, _, err := p.parseLine(b, i+1)
+		if err != nil {
+			return nil, err
+		}
+		if parsed != nil && jt != nil {
+			return nil, fmt.Errorf("line %d: the block holds more than one thread", i+1)
+		} else if parsed != nil {
+			jt = parsed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read the thread block: %v", err)
+	}
+	p.flush()
+	if jt == nil {
+		return nil, fmt.Errorf("couldn't find a thread header")
+	}
+	jt.hashing = opts.stackHashing()
+	jt.analyze()
+	return jt, nil
+}
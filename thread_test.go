@@ -0,0 +1,170 @@
+package jstackparser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLockBeforeFirstFrame(t *testing.T) {
+	dump := `2019-08-20 10:37:05
+Full thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):
+
+"owner" #30 prio=5 os_prio=0 tid=0x00007f5c7c4b6000 nid=0x6b9e runnable [0x00007f5c2a7f6000]
+   java.lang.Thread.State: RUNNABLE
+	- locked <0x00000000c0a1b2d0> (a java.lang.Object)
+	at com.acme.Service.doWork(Service.java:42)
+	at java.lang.Thread.run(Thread.java:748)
+
+"waiter" #31 prio=5 os_prio=0 tid=0x00007f5c7c4b7000 nid=0x6b9f waiting for monitor entry [0x00007f5c2a6f5000]
+   java.lang.Thread.State: BLOCKED (on object monitor)
+	at com.acme.Service.doWork(Service.java:40)
+	- waiting to lock <0x00000000c0a1b2d0> (a java.lang.Object)
+	at java.lang.Thread.run(Thread.java:748)
+
+JNI global references: 12
+`
+	jtd, err := ParseJStack(dump)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := jtd.Threads["0x00007f5c7c4b6000"]
+	if len(owner.LocksOwned) != 1 || owner.LocksOwned[0] != "0x00000000c0a1b2d0" {
+		t.Errorf("LocksOwned = %v, want the lock printed before the first frame", owner.LocksOwned)
+	}
+	if jtd.LockOwners["0x00000000c0a1b2d0"] != owner.TID {
+		t.Errorf("LockOwners = %v, want the lock owned by %s", jtd.LockOwners, owner.TID)
+	}
+	if problems := jtd.Analysis.ByCategory(CategoryContention); len(problems) != 1 || problems[0].TIDs[1] != owner.TID {
+		t.Errorf("contention problems = %v, want the waiter blocked for the owner", problems)
+	}
+}
+
+func TestLongListing(t *testing.T) {
+	plain, err := ParseJStack(readFixture(t, "jdk8.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	long, err := ParseJStack(readFixture(t, "jdk8_long.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(long.Threads) != len(plain.Threads) || len(long.SystemThreads) != len(plain.SystemThreads) {
+		t.Fatalf("jstack -l gave %d threads and %d system threads, want %d and %d", len(long.Threads), len(long.SystemThreads), len(plain.Threads), len(plain.SystemThreads))
+	}
+	for tid, p := range plain.Threads {
+		l := long.Threads[tid]
+		if l == nil {
+			t.Errorf("%s %s missing with jstack -l", p.Name, tid)
+			continue
+		}
+		// The fields both outputs have, the stack shows that the synchronizers don't leak into the next thread.
+		shared := func(jt *JavaThread) []interface{} {
+			return []interface{}{jt.Name, jt.Status, jt.Stack, jt.StackHash, jt.LocksOwned, jt.LocksWaiting, jt.WaitingOn, jt.LocksReacquiring, jt.ParkingOn}
+		}
+		if !reflect.DeepEqual(shared(l), shared(p)) {
+			t.Errorf("%s %s with jstack -l = %v, want %v", p.Name, tid, shared(l), shared(p))
+		}
+		if len(p.OwnableSynchronizers) != 0 {
+			t.Errorf("%s %s has ownable synchronizers without jstack -l: %v", p.Name, tid, p.OwnableSynchronizers)
+		}
+	}
+	if main := long.MainThread(); !reflect.DeepEqual(main.OwnableSynchronizers, []string{"0x00000000c0a1b400"}) {
+		t.Errorf("main OwnableSynchronizers = %v, want [0x00000000c0a1b400]", main.OwnableSynchronizers)
+	}
+	for lock, owner := range plain.LockOwners {
+		if long.LockOwners[lock] != owner {
+			t.Errorf("lock %s owned by %q with jstack -l, want %q", lock, long.LockOwners[lock], owner)
+		}
+	}
+	if !reflect.DeepEqual(long.DetectDeadlocks(), plain.DetectDeadlocks()) {
+		t.Errorf("deadlocks with jstack -l = %v, want %v", long.DetectDeadlocks(), plain.DetectDeadlocks())
+	}
+}
+
+func TestJDK7ThreadsWithoutNumber(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "jdk7.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jtd.TotalThreads != 3 || len(jtd.SystemThreads) != 4 {
+		t.Fatalf("TotalThreads = %d and %d system threads, want 3 and 4", jtd.TotalThreads, len(jtd.SystemThreads))
+	}
+	if main := jtd.MainThread(); main == nil || main.Prio != 10 || main.Status != StatusRunnable {
+		t.Errorf("main = %+v, want the RUNNABLE main thread of prio 10", main)
+	}
+	if attach := jtd.Threads["0x00007f3d88001000"]; attach == nil || !attach.IsDaemon {
+		t.Errorf("Attach Listener = %+v, want a daemon application thread", attach)
+	}
+	if vm := jtd.SystemThreads["0x00007f3dbc0b7000"]; vm == nil || vm.Name != "VM Thread" || vm.Status != StatusRunnable {
+		t.Errorf("VM Thread = %+v, want a RUNNABLE system thread", vm)
+	}
+}
+
+func TestHeadersWithoutFields(t *testing.T) {
+	jtd, err := ParseJStack(readFixture(t, "headers.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tid, name, nid, status string
+		prio, osPrio           int
+	}{
+		{"0x00007f5c7c00a000", "main", "31348", StatusRunnable, 5, 0},
+		{"0x00007f5c7c4b6000", `worker "a"`, "0x6b9e", StatusWaiting, 0, 0},
+		{"0x00007f5c7c4b7000", "graal", "0x6b9f", StatusRunnable, 0, 0},
+	}
+	for _, tt := range tests {
+		jt := jtd.Threads[tt.tid]
+		if jt == nil {
+			t.Errorf("thread %s %q not captured", tt.tid, tt.name)
+			continue
+		}
+		if jt.Name != tt.name || jt.NID != tt.nid || jt.Status != tt.status || jt.Prio != tt.prio || jt.OSPrio != tt.osPrio {
+			t.Errorf("thread %s = %q nid=%s %s prio=%d os_prio=%d, want %q nid=%s %s prio=%d os_prio=%d", tt.tid,
+				jt.Name, jt.NID, jt.Status, jt.Prio, jt.OSPrio, tt.name, tt.nid, tt.status, tt.prio, tt.osPrio)
+		}
+	}
+	if main := jtd.Threads["0x00007f5c7c00a000"]; main != nil && main.NativeID != 31348 {
+		t.Errorf("main NativeID = %d, want 31348", main.NativeID)
+	}
+	// The header without nid can't be parsed, losing the thread must be reported.
+	if len(jtd.Threads) != 3 || len(jtd.ParseWarnings) != 1 || !strings.Contains(jtd.ParseWarnings[0], `"cut"`) {
+		t.Errorf("%d threads and warnings %q, want 3 threads and a warning about the header of cut", len(jtd.Threads), jtd.ParseWarnings)
+	}
+	// Nor may its stack and lock go to the next thread.
+	if graal := jtd.Threads["0x00007f5c7c4b7000"]; graal != nil && (len(graal.Stack) != 1 || len(graal.LocksOwned) != 0) {
+		t.Errorf("graal stack %q and LocksOwned %v, want its only frame and no lock", graal.Stack, graal.LocksOwned)
+	}
+	if owner, ok := jtd.LockOwners["0x00000000c0a1b299"]; ok {
+		t.Errorf("lock of cut owned by %s, want it dropped", owner)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	const head = "2019-08-20 10:37:05\nFull thread dump Java HotSpot(TM) 64-Bit Server VM (25.201-b09 mixed mode):\n\n"
+	const worker = "\"worker\" #21 prio=5 os_prio=0 tid=0x00007f5c7c4b6000 nid=0x6b9e runnable [0x00007f5c2a7f6000]\n" +
+		"   java.lang.Thread.State: RUNNABLE\n\tat com.acme.Worker.run(Worker.java:12)\n"
+	tests := []struct {
+		name, dump, wantErr string
+	}{
+		{"clean", head + worker + "\nJNI global references: 12\n", ""},
+		{"header", head + "\"cut\" #20 prio=5 os_prio=0 tid=0x00007f5c7c4b5000\n\n" + worker, "line 4: couldn't parse the thread header"},
+		{"lock", head + worker + "\t- locked (a java.lang.Object)\n", "line 7: couldn't find the lock ID"},
+	}
+	for _, tt := range tests {
+		_, err := ParseJStack(tt.dump, WithStrict())
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: strict parsing failed: %v", tt.name, err)
+		} else if tt.wantErr != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: strict parsing error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		// Without Strict the line is reported and the rest of the dump parsed.
+		jtd, err := ParseJStack(tt.dump)
+		if err != nil {
+			t.Errorf("%s: parsing failed: %v", tt.name, err)
+		} else if jtd.TotalThreads != 1 || (tt.wantErr == "") != (len(jtd.ParseWarnings) == 0) {
+			t.Errorf("%s: %d threads and warnings %q, want the worker and a warning for a malformed line", tt.name, jtd.TotalThreads, jtd.ParseWarnings)
+		}
+	}
+}